@@ -0,0 +1,356 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// User est un compte enregistré.
+type User struct {
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"password_hash"`
+	Created      time.Time `json:"created"`
+	Admin        bool      `json:"admin,omitempty"` // Accès à /admin, accordé par la commande admin
+}
+
+// Session relie un navigateur (cookie) à sa partie en cours et, s'il est connecté, à son compte.
+// Les sessions invitées restent en mémoire ; celles des joueurs connectés sont enregistrées
+// dans la base pour survivre à un redémarrage.
+type Session struct {
+	ID      string    `json:"id"`
+	User    string    `json:"user,omitempty"`
+	GameID  string    `json:"game_id,omitempty"`
+	CSRF    string    `json:"csrf,omitempty"` // Jeton joint aux formulaires de la page de jeu
+	Expires time.Time `json:"expires"`
+	// Dernière requête du navigateur, pour la métrique des sessions actives (non enregistrée)
+	LastSeen time.Time `json:"-"`
+}
+
+const (
+	sessionCookie      = "p4_session"
+	sessionLifetime    = 30 * 24 * time.Hour
+	guestSessionIdle   = 2 * time.Hour // Session invitée sans requête : oubliée
+	passwordIterations = 210000
+	minPasswordLength  = 8
+)
+
+// Les noms de compte servent aussi de noms de joueur : courts et sans caractères spéciaux.
+var accountNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{3,16}$`)
+
+// Sessions actives, protégées par mutex comme les parties.
+var sessions = map[string]*Session{}
+
+// hashPassword dérive le mot de passe avec PBKDF2-SHA256 et un sel aléatoire.
+// Format : pbkdf2-sha256$<itérations>$<sel>$<clé>, en base64.
+func hashPassword(password string) (string, error) {
+	salt := []byte(newID(16))
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// checkPassword compare un mot de passe à son empreinte en temps constant.
+func checkPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err1 := enc.DecodeString(parts[2])
+	want, err2 := enc.DecodeString(parts[3])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// lookupUser retourne le compte portant ce nom (sans tenir compte de la casse), ou nil.
+func lookupUser(name string) *User {
+	var user *User
+	store.View(func(d *storeData) {
+		user = d.Users[strings.ToLower(name)]
+	})
+	return user
+}
+
+// registerUser crée un compte après avoir validé le nom et le mot de passe.
+func registerUser(name, password string) (*User, error) {
+	if !accountNameRe.MatchString(name) {
+		return nil, errors.New("le nom doit faire 3 à 16 caractères (lettres, chiffres, _ ou -)")
+	}
+	if strings.EqualFold(name, "IA") {
+		return nil, errors.New("ce nom est réservé")
+	}
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("le mot de passe doit faire au moins %d caractères", minPasswordLength)
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &User{Name: name, PasswordHash: hash, Created: time.Now()}
+	err = store.Update(func(d *storeData) error {
+		key := strings.ToLower(name)
+		if d.Users[key] != nil {
+			return errors.New("ce nom est déjà pris")
+		}
+		d.Users[key] = user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// loadSessions restaure les sessions des joueurs connectés enregistrées dans la base.
+func loadSessions() {
+	now := time.Now()
+	store.View(func(d *storeData) {
+		for id, s := range d.Sessions {
+			if s.Expires.After(now) {
+				sessions[id] = s
+			}
+		}
+	})
+}
+
+// csrfToken retourne le jeton anti-CSRF de la session (créé pour les sessions enregistrées
+// avant son introduction). Doit être appelé avec mutex verrouillé.
+func (s *Session) csrfToken() string {
+	if s.CSRF == "" {
+		s.CSRF = newID(16)
+	}
+	return s.CSRF
+}
+
+// checkCSRF vérifie que la requête porte le jeton de la session, dans le champ csrf d'un
+// formulaire ou dans l'en-tête X-CSRF-Token. Doit être appelé avec mutex verrouillé.
+func checkCSRF(s *Session, r *http.Request) bool {
+	token := r.Header.Get("X-CSRF-Token")
+	if token == "" {
+		token = r.FormValue("csrf")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.csrfToken())) == 1
+}
+
+// setSessionCookie envoie le cookie de session au navigateur. SameSite=Lax : le navigateur
+// n'envoie pas le cookie avec un formulaire POST soumis depuis un autre site, mais le garde
+// pour les liens d'invitation ouverts depuis une messagerie.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, s *Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    s.ID,
+		Path:     "/",
+		Expires:  s.Expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// expired indique si la session est à oublier : arrivée à expiration ou, pour un invité, sans
+// requête depuis guestSessionIdle. Une session invitée est ouverte par la simple visite d'une
+// page de jeu : elle ne dure que tant que le navigateur s'en sert.
+func (s *Session) expired(now time.Time) bool {
+	return !s.Expires.After(now) || s.User == "" && now.Sub(s.LastSeen) > guestSessionIdle
+}
+
+// findSession retourne la session du navigateur, ou nil s'il n'en a pas : les pages qui ne
+// font que lire n'en créent pas. Doit être appelé avec mutex verrouillé.
+func findSession(r *http.Request) *Session {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		now := time.Now()
+		if s := sessions[c.Value]; s != nil && !s.expired(now) {
+			s.LastSeen = now
+			return s
+		}
+	}
+	return nil
+}
+
+// sessionUser retourne le compte connecté du navigateur, ou "" pour un invité. Doit être
+// appelé avec mutex verrouillé.
+func sessionUser(r *http.Request) string {
+	if s := findSession(r); s != nil {
+		return s.User
+	}
+	return ""
+}
+
+// currentSession retourne la session du navigateur, en créant une session invitée si besoin :
+// pour jouer, ou se connecter. Doit être appelé avec mutex verrouillé.
+func currentSession(w http.ResponseWriter, r *http.Request) *Session {
+	if s := findSession(r); s != nil {
+		return s
+	}
+	now := time.Now()
+	s := &Session{ID: newID(24), CSRF: newID(16), Expires: now.Add(sessionLifetime), LastSeen: now}
+	sessions[s.ID] = s
+	setSessionCookie(w, r, s)
+	return s
+}
+
+// logIn attache le compte à une nouvelle session (nouvel identifiant pour éviter la fixation
+// de session) en conservant la partie en cours, et retourne cette session. Doit être appelé
+// avec mutex verrouillé.
+func logIn(w http.ResponseWriter, r *http.Request, user *User) (*Session, error) {
+	now := time.Now()
+	s := &Session{ID: newID(24), User: user.Name, CSRF: newID(16), Expires: now.Add(sessionLifetime), LastSeen: now}
+	old := findSession(r)
+	if old != nil {
+		s.GameID = old.GameID
+		delete(sessions, old.ID)
+		moveGuestSeats(old.ID, s.ID)
+	}
+	sessions[s.ID] = s
+	err := store.Update(func(d *storeData) error {
+		if old != nil {
+			delete(d.Sessions, old.ID)
+		}
+		d.Sessions[s.ID] = s
+		return nil
+	})
+	setSessionCookie(w, r, s)
+	return s, err
+}
+
+// --- Handlers des comptes ---
+
+// registerHandler affiche et traite le formulaire de création de compte.
+func registerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		render(w, r, accountTmpl, map[string]interface{}{"Register": true})
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	user, err := registerUser(name, r.FormValue("password"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render(w, r, accountTmpl, map[string]interface{}{"Register": true, "Name": name, "Error": err.Error()})
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, err := logIn(w, r, user); err != nil {
+		requestLogger(r).Error("enregistrement de la session", "user", user.Name, "err", err)
+	}
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
+
+// loginHandler affiche et traite le formulaire de connexion.
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		render(w, r, accountTmpl, map[string]interface{}{})
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	// La vérification du mot de passe est coûteuse : elle se fait hors du verrou global
+	user := lookupUser(name)
+	if user == nil || !checkPassword(user.PasswordHash, r.FormValue("password")) {
+		w.WriteHeader(http.StatusUnauthorized)
+		render(w, r, accountTmpl, map[string]interface{}{"Name": name, "Error": "Nom ou mot de passe incorrect"})
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, err := logIn(w, r, user); err != nil {
+		requestLogger(r).Error("enregistrement de la session", "user", user.Name, "err", err)
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// logoutHandler déconnecte le joueur ; il garde sa partie en cours en tant qu'invité.
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	old := findSession(r)
+	if old != nil && old.User != "" {
+		err := store.Update(func(d *storeData) error {
+			delete(d.Sessions, old.ID)
+			return nil
+		})
+		if err != nil {
+			requestLogger(r).Error("suppression de la session", "user", old.User, "err", err)
+		}
+		delete(sessions, old.ID)
+		now := time.Now()
+		s := &Session{ID: newID(24), GameID: old.GameID, CSRF: newID(16), Expires: now.Add(sessionLifetime), LastSeen: now}
+		sessions[s.ID] = s
+		moveGuestSeats(old.ID, s.ID)
+		setSessionCookie(w, r, s)
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// profileHandler affiche le profil d'un compte (le sien par défaut).
+func profileHandler(w http.ResponseWriter, r *http.Request) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	viewer := sessionUser(r)
+	name := r.URL.Query().Get("user")
+	if name == "" {
+		if viewer == "" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		name = viewer
+	}
+	user := lookupUser(name)
+	if user == nil {
+		http.Error(w, "Joueur inconnu", http.StatusNotFound)
+		return
+	}
+	// Parties en cours où le compte occupe un siège
+	type activeGame struct {
+		ID       string
+		Opponent string
+		Turn     bool
+	}
+	var active []activeGame
+	for _, g := range games {
+		if g.GameOver {
+			continue
+		}
+		if seat := g.seatOf(user.Name); seat != 0 {
+			var others []string
+			for p := 1; p <= g.Players; p++ {
+				if p != seat {
+					others = append(others, g.playerName(p))
+				}
+			}
+			active = append(active, activeGame{ID: g.ID, Opponent: strings.Join(others, ", "), Turn: g.CurrentPlayer == seat})
+		}
+	}
+	render(w, r, profileTmpl, map[string]interface{}{
+		"User":    user,
+		"Self":    strings.EqualFold(viewer, user.Name),
+		"Since":   user.Created.Format("02/01/2006"),
+		"Active":  active,
+		"Viewer":  viewer,
+		"IsGuest": viewer == "",
+		"Rating":  lookupRating(user.Name),
+		"Initial": initialRating,
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Sondes de l'hébergeur (Coolify) et page d'administration.
+//
+//	GET /healthz   le processus répond
+//	GET /readyz    le serveur peut servir : templates chargés, base accessible en écriture
+//	/admin         parties en cours, inspection d'un plateau, fin ou suppression d'une partie,
+//	               délai de l'IA ; réservé aux comptes désignés par la commande admin
+
+// Délai maximal avant le coup de l'IA accepté depuis /admin, en millisecondes.
+const maxAIDelayMs = 10000
+
+// shuttingDown passe à vrai à l'arrêt du serveur : /readyz signale alors qu'il ne faut plus
+// lui envoyer de trafic.
+var shuttingDown atomic.Bool
+
+// isAdmin indique si la session est celle d'un compte administrateur. Le droit est porté par
+// le compte dans la base, pas par son nom : un nom d'administrateur pas encore enregistré ne
+// donne rien à qui le prendrait.
+func isAdmin(s *Session) bool {
+	if s.User == "" {
+		return false
+	}
+	var admin bool
+	store.View(func(d *storeData) {
+		if u := d.Users[strings.ToLower(s.User)]; u != nil {
+			admin = u.Admin
+		}
+	})
+	return admin
+}
+
+// healthzHandler répond tant que le processus tourne.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte("ok\n"))
+}
+
+// templatesLoaded indique si tous les templates de pages sont chargés.
+func templatesLoaded() bool {
+	for _, t := range []any{pageTmpl, startTmpl, winTmpl, loseTmpl, modeTmpl, accountTmpl, profileTmpl, leaderboardTmpl,
+		statsTmpl, matchmakingTmpl, tournamentsTmpl, tournamentTmpl, errorTmpl, adminTmpl} {
+		if t == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// readyzHandler vérifie que le serveur peut servir les joueurs.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	var problem string
+	switch {
+	case shuttingDown.Load():
+		problem = "arrêt en cours"
+	case !templatesLoaded():
+		problem = "templates non chargés"
+	case store == nil:
+		problem = "base non ouverte"
+	default:
+		if err := store.Ping(); err != nil {
+			problem = "base inaccessible : " + err.Error()
+		}
+	}
+	if problem != "" {
+		requestLogger(r).Warn("serveur non prêt", "reason", problem)
+		http.Error(w, problem, http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// adminGame décrit une partie dans la liste de la page d'administration.
+type adminGame struct {
+	ID         string
+	Players    string
+	GameMode   string
+	Mode       string
+	Difficulty string
+	Moves      int
+	Started    time.Time
+	GameOver   bool
+	Tournament string
+	Watchers   int
+}
+
+// adminGames liste les parties, les plus récentes en premier. Doit être appelé avec mutex verrouillé.
+func adminGames() []adminGame {
+	list := make([]adminGame, 0, len(games))
+	for _, g := range games {
+		names := make([]string, 0, g.Players)
+		for p := 1; p <= g.Players; p++ {
+			names = append(names, g.playerName(p))
+		}
+		list = append(list, adminGame{
+			ID:         g.ID,
+			Players:    strings.Join(names, ", "),
+			GameMode:   g.GameMode.String(),
+			Mode:       labelOr(modeLabels, g.Mode),
+			Difficulty: labelOr(difficultyLabels, g.Difficulty),
+			Moves:      len(g.Moves),
+			Started:    g.Started,
+			GameOver:   g.GameOver,
+			Tournament: g.Tournament,
+			Watchers:   len(gameWatchers[g.ID]),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.After(list[j].Started) })
+	return list
+}
+
+// replayTournamentGame relance la rencontre de tournoi d'une partie close ou supprimée depuis
+// l'administration. Doit être appelé avec mutex verrouillé.
+func replayTournamentGame(g *Game) {
+	if t := tournaments[g.Tournament]; t != nil && !t.Finished {
+		t.startGames()
+		saveTournament(t)
+	}
+}
+
+// adminHandler affiche la page d'administration et traite ses actions.
+func adminHandler(w http.ResponseWriter, r *http.Request) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	sess := findSession(r)
+	if sess == nil || sess.User == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if !isAdmin(sess) {
+		renderError(w, r, http.StatusForbidden, "Page réservée aux administrateurs.")
+		return
+	}
+
+	if r.Method == "POST" {
+		r.ParseForm()
+		if !checkCSRF(sess, r) {
+			renderError(w, r, http.StatusForbidden, "Formulaire expiré : rechargez la page d'administration.")
+			return
+		}
+		logger := requestLogger(r).With("admin", sess.User)
+		g := games[r.FormValue("game")]
+		switch r.FormValue("action") {
+		case "end":
+			// Partie close sans résultat : enregistrée comme telle, sans statistiques ni
+			// classement (une partie de tournoi est rejouée, voir settleGame)
+			if g != nil && !g.GameOver {
+				g.GameOver, g.Winner = true, 0
+				g.Aborted = true
+				settleGame(g)
+				notifyGame(g)
+				logger.Info("partie terminée par l'administration", "game", g.ID)
+			}
+		case "delete":
+			if g != nil {
+				// Les flux d'événements de la partie se ferment en ne la trouvant plus
+				notifyGame(g)
+				delete(games, g.ID)
+				replayTournamentGame(g)
+				logger.Info("partie supprimée par l'administration", "game", g.ID)
+			}
+		case "ai-delay":
+			delay, err := strconv.Atoi(r.FormValue("ai_delay_ms"))
+			if err != nil || delay < 0 || delay > maxAIDelayMs {
+				renderError(w, r, http.StatusBadRequest, "Délai de l'IA invalide : de 0 à "+strconv.Itoa(maxAIDelayMs)+" ms.")
+				return
+			}
+			tuning.AIDelayMs = delay
+			logger.Info("délai de l'IA modifié", "ai_delay_ms", delay)
+		}
+		target := "/admin"
+		if g != nil && r.FormValue("action") != "delete" {
+			target += "?game=" + g.ID
+		}
+		http.Redirect(w, r, target, http.StatusSeeOther)
+		return
+	}
+
+	data := map[string]interface{}{
+		"User":      sess.User,
+		"Games":     adminGames(),
+		"AIDelayMs": tuning.AIDelayMs,
+		"MaxDelay":  maxAIDelayMs,
+		"CSRF":      sess.csrfToken(),
+	}
+	if g := games[r.URL.Query().Get("game")]; g != nil {
+		data["Game"] = g
+		data["State"] = g.state()
+	}
+	render(w, r, adminTmpl, data)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"power4/engine"
+)
+
+// API JSON des parties, pour les clients autres que le navigateur (client terminal) :
+//
+//	POST /api/session          ouvre la session du client et retourne son jeton anti-CSRF ;
+//	                           name et password la connectent à un compte, game la rattache à une partie
+//	POST /api/games            crée une partie (mêmes paramètres que /connect4)
+//	GET  /api/game?game=ID     état de la partie
+//	POST /api/game?game=ID     joue col=<indice> (player=<siège> refuse le coup si ce n'est pas son tour),
+//	                           ou ai=1 : le coup de l'IA quand c'est son tour
+//	GET  /api/events?game=ID   flux server-sent events : l'état à chaque changement
+//
+// Les requêtes POST autres que /api/session portent le cookie de session et le jeton dans
+// l'en-tête X-CSRF-Token ; un coup n'est accepté que de qui peut jouer ce siège, comme sur la
+// page de jeu. Le flux d'événements est facultatif : un client peut se contenter d'interroger
+// /api/game.
+
+// gameState est l'état d'une partie tel que le sert l'API.
+type gameState struct {
+	ID            string        `json:"id"`
+	Version       int           `json:"version"`
+	Rows          int           `json:"rows"`
+	Cols          int           `json:"cols"`
+	Board         [][]int       `json:"board"`
+	Mode          string        `json:"mode"`
+	Gravity       string        `json:"gravity"`
+	Players       []statePlayer `json:"players"`
+	CurrentPlayer int           `json:"current_player"`
+	VsAI          bool          `json:"vs_ai"`
+	AILevel       string        `json:"ai_level,omitempty"`
+	AIDelayMs     int           `json:"ai_delay_ms,omitempty"`
+	Moves         []int         `json:"moves"`
+	Notation      string        `json:"notation"`
+	ValidMoves    []int         `json:"valid_moves"`
+	LastRow       int           `json:"last_row"`
+	LastCol       int           `json:"last_col"`
+	GameOver      bool          `json:"game_over"`
+	Winner        int           `json:"winner"`
+	Next          string        `json:"next,omitempty"`
+}
+
+// statePlayer décrit un siège de la partie.
+type statePlayer struct {
+	Name       string `json:"name"`
+	Color      string `json:"color"`
+	Eliminated bool   `json:"eliminated,omitempty"`
+}
+
+// state retourne l'état de la partie pour l'API.
+func (g *Game) state() gameState {
+	s := gameState{
+		ID:            g.ID,
+		Version:       g.Version,
+		Rows:          g.Rows,
+		Cols:          g.Cols,
+		Board:         engine.CopyBoard(g.Board),
+		Mode:          g.Mode,
+		Gravity:       g.Gravity.String(),
+		CurrentPlayer: g.CurrentPlayer,
+		VsAI:          g.GameMode == ModeHumanVsAI,
+		Moves:         append([]int{}, g.Moves...),
+		Notation:      formatMoves(g.Moves),
+		ValidMoves:    append([]int{}, g.ValidMoves()...),
+		LastRow:       g.LastRow,
+		LastCol:       g.LastCol,
+		GameOver:      g.GameOver,
+		Winner:        g.Winner,
+		Next:          g.Next,
+	}
+	if s.VsAI {
+		s.AILevel = g.AILevel.String()
+		s.AIDelayMs = g.aiDelay()
+	}
+	for p := 1; p <= g.Players; p++ {
+		s.Players = append(s.Players, statePlayer{
+			Name:       g.playerName(p),
+			Color:      g.colorOf(p),
+			Eliminated: g.Eliminated[p-1],
+		})
+	}
+	return s
+}
+
+// Abonnés au flux d'événements de chaque partie, protégés par mutex.
+var gameWatchers = map[string]map[chan struct{}]bool{}
+
+// watchGame abonne un flux aux changements de la partie id. À appeler sous mutex.
+func watchGame(id string) chan struct{} {
+	ch := make(chan struct{}, 1)
+	if gameWatchers[id] == nil {
+		gameWatchers[id] = map[chan struct{}]bool{}
+	}
+	gameWatchers[id][ch] = true
+	return ch
+}
+
+// unwatchGame désabonne un flux. À appeler sous mutex.
+func unwatchGame(id string, ch chan struct{}) {
+	delete(gameWatchers[id], ch)
+	if len(gameWatchers[id]) == 0 {
+		delete(gameWatchers, id)
+	}
+}
+
+// notifyGame signale un changement de la partie à ses abonnés. À appeler sous mutex.
+func notifyGame(g *Game) {
+	g.Version++
+	g.Active = time.Now()
+	for ch := range gameWatchers[g.ID] {
+		select {
+		case ch <- struct{}{}:
+		default: // Un changement est déjà en attente pour cet abonné
+		}
+	}
+}
+
+// writeJSON envoie v en JSON avec le code status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// apiError envoie une erreur JSON {"error": msg}.
+func apiError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// apiSession est la réponse de /api/session.
+type apiSession struct {
+	CSRF string `json:"csrf"`
+	User string `json:"user,omitempty"`
+}
+
+// apiSessionHandler ouvre la session d'un client de l'API, connectée au compte name si le mot
+// de passe est donné, et la rattache à la partie game comme l'ouverture de sa page.
+func apiSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		apiError(w, http.StatusMethodNotAllowed, "Méthode non autorisée")
+		return
+	}
+	r.ParseForm()
+	var user *User
+	if name := strings.TrimSpace(r.FormValue("name")); name != "" {
+		// La vérification du mot de passe est coûteuse : elle se fait hors du verrou global
+		user = lookupUser(name)
+		if user == nil || !checkPassword(user.PasswordHash, r.FormValue("password")) {
+			apiError(w, http.StatusUnauthorized, "Nom ou mot de passe incorrect")
+			return
+		}
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	var sess *Session
+	if user != nil {
+		var err error
+		if sess, err = logIn(w, r, user); err != nil {
+			requestLogger(r).Error("enregistrement de la session", "user", user.Name, "err", err)
+		}
+	} else {
+		sess = currentSession(w, r)
+	}
+	if id := r.FormValue("game"); id != "" {
+		if games[id] == nil {
+			apiError(w, http.StatusNotFound, "Partie introuvable")
+			return
+		}
+		sess.GameID = id
+	}
+	writeJSON(w, http.StatusOK, apiSession{CSRF: sess.csrfToken(), User: sess.User})
+}
+
+// apiGamesHandler crée une partie à partir des paramètres du formulaire d'accueil. Le compte
+// de la session occupe le premier siège.
+func apiGamesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		apiError(w, http.StatusMethodNotAllowed, "Méthode non autorisée")
+		return
+	}
+	r.ParseForm()
+	mutex.Lock()
+	defer mutex.Unlock()
+	sess := findSession(r)
+	if sess == nil || !checkCSRF(sess, r) {
+		apiError(w, http.StatusForbidden, "Jeton CSRF invalide : ouvrez une session avec POST /api/session")
+		return
+	}
+	if sess.User != "" {
+		r.Form.Set("username", sess.User)
+	}
+	g, err := gameFromQuery(r.Form)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if sess.User != "" {
+		g.Accounts[0] = sess.User
+	}
+	games[g.ID] = g
+	sess.GameID = g.ID
+	writeJSON(w, http.StatusCreated, g.state())
+}
+
+// apiGameHandler sert l'état d'une partie (GET) ou y joue un coup (POST).
+func apiGameHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" && r.FormValue("ai") == "1" {
+		if !acquireAI(w, r) {
+			return
+		}
+		defer releaseAI()
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	g := games[r.URL.Query().Get("game")]
+	if g == nil {
+		apiError(w, http.StatusNotFound, "Partie introuvable")
+		return
+	}
+	g.CheckFlag()
+	settleGame(g)
+	switch r.Method {
+	case "GET":
+	case "POST":
+		r.ParseForm()
+		sess := findSession(r)
+		if sess == nil || !checkCSRF(sess, r) {
+			apiError(w, http.StatusForbidden, "Jeton CSRF invalide : ouvrez une session avec POST /api/session")
+			return
+		}
+		if r.FormValue("ai") == "1" {
+			// Comme pour /ai-move, seul le joueur qui suit la partie déclenche le coup de l'IA
+			if sess.GameID != g.ID || !g.takeSeat(sess, 1) {
+				apiError(w, http.StatusForbidden, "Partie d'une autre session")
+				return
+			}
+			if g.GameMode != ModeHumanVsAI || g.GameOver || g.CurrentPlayer != 2 {
+				apiError(w, http.StatusConflict, "Ce n'est pas le tour de l'IA")
+				return
+			}
+			if col := aiDecision(r, g); col >= 0 && g.DropToken(col) {
+				movePlayed(r, g, 2)
+				settleGame(g)
+				notifyGame(g)
+			}
+			break
+		}
+		index, err := strconv.Atoi(r.FormValue("col"))
+		if err != nil {
+			apiError(w, http.StatusBadRequest, "Coup invalide : "+r.FormValue("col"))
+			return
+		}
+		if p := r.FormValue("player"); p != "" && p != strconv.Itoa(g.CurrentPlayer) {
+			apiError(w, http.StatusConflict, "Ce n'est pas le tour du joueur "+p)
+			return
+		}
+		if g.GameMode == ModeHumanVsAI && g.CurrentPlayer == 2 {
+			apiError(w, http.StatusConflict, "C'est au tour de l'IA")
+			return
+		}
+		if !g.mayPlay(sess, g.CurrentPlayer) {
+			apiError(w, http.StatusForbidden, "Ce n'est pas à vous de jouer")
+			return
+		}
+		player := g.CurrentPlayer
+		if !g.DropToken(index) {
+			apiError(w, http.StatusConflict, "Coup impossible")
+			return
+		}
+		g.takeSeat(sess, player)
+		movePlayed(r, g, player)
+		settleGame(g)
+		notifyGame(g)
+	default:
+		apiError(w, http.StatusMethodNotAllowed, "Méthode non autorisée")
+		return
+	}
+	writeJSON(w, http.StatusOK, g.state())
+}
+
+// gameEventsHandler diffuse l'état d'une partie en server-sent events : l'état courant dès la
+// connexion, puis à chaque changement. Un commentaire régulier garde la connexion ouverte.
+func gameEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apiError(w, http.StatusInternalServerError, "Flux non pris en charge")
+		return
+	}
+	ip, ok := acquireStream(w, r)
+	if !ok {
+		return
+	}
+	defer releaseStream(ip)
+	id := r.URL.Query().Get("game")
+	mutex.Lock()
+	g := games[id]
+	if g == nil {
+		mutex.Unlock()
+		apiError(w, http.StatusNotFound, "Partie introuvable")
+		return
+	}
+	ch := watchGame(id)
+	state := g.state()
+	mutex.Unlock()
+	defer func() {
+		mutex.Lock()
+		unwatchGame(id, ch)
+		mutex.Unlock()
+	}()
+
+	// Le flux reste ouvert toute la partie : pas de délais de lecture ni d'écriture pour cette connexion
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	send := func(s gameState) {
+		data, _ := json.Marshal(s)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+	send(state)
+	ping := time.NewTicker(15 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-ch:
+			mutex.Lock()
+			g := games[id]
+			if g != nil {
+				state = g.state()
+			}
+			mutex.Unlock()
+			if g == nil {
+				return
+			}
+			send(state)
+		}
+	}
+}
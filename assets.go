@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Fichiers du site, embarqués dans l'exécutable : templates, feuille de style, icône, scripts
+// et plateaux de départ (voir layout.go).
+// Les fichiers statiques sont référencés avec leur empreinte dans l'URL (fonction asset des
+// templates) et mis en cache un an par le navigateur ; ils sont envoyés compressés en gzip
+// quand le navigateur l'accepte. En mode -dev, templates, fichiers statiques et plateaux sont
+// relus sur le disque (server.templates, server.static, game.layouts) à chaque requête, sans
+// cache.
+
+//go:embed templates/*.html style.css favicon.svg game.js start.js layouts/*.txt
+var embeddedFiles embed.FS
+
+// Fichiers statiques servis à la racine du site.
+var staticFiles = []string{"style.css", "favicon.svg", "game.js", "start.js"}
+
+// Durée de cache d'un fichier statique demandé avec son empreinte : son contenu ne changera pas.
+const immutableCache = "public, max-age=31536000, immutable"
+
+// asset est un fichier statique prêt à servir.
+type asset struct {
+	body        []byte
+	gzipped     []byte // nil si la compression ne fait rien gagner
+	hash        string // Début du SHA-256 du contenu
+	contentType string
+}
+
+var assets = map[string]*asset{}
+
+// loadAssets prépare les fichiers statiques embarqués : empreinte et version compressée.
+func loadAssets() error {
+	for _, name := range staticFiles {
+		body, err := fs.ReadFile(embeddedFiles, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(body)
+		a := &asset{body: body, hash: hex.EncodeToString(sum[:6]), contentType: mime.TypeByExtension(path.Ext(name))}
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		zw.Write(body)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		if buf.Len() < len(body) {
+			a.gzipped = buf.Bytes()
+		}
+		assets[name] = a
+	}
+	return nil
+}
+
+// assetURL retourne l'URL d'un fichier statique, avec son empreinte hors mode -dev.
+func assetURL(name string) string {
+	if a := assets[name]; a != nil && !config.Server.Dev {
+		return "/" + name + "?v=" + a.hash
+	}
+	return "/" + name
+}
+
+// staticFile retourne le chemin d'un fichier statique sur le disque, dans le dossier server.static.
+func staticFile(name string) string {
+	return filepath.Join(config.Server.Static, name)
+}
+
+// acceptsEncoding indique si la requête accepte l'encodage coding (en-tête Accept-Encoding,
+// q=0 valant refus).
+func acceptsEncoding(r *http.Request, coding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), coding) && strings.TrimSpace(name) != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// assetHandler sert le fichier statique name.
+func assetHandler(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if config.Server.Dev {
+			h.Set("Cache-Control", "no-cache")
+			http.ServeFile(w, r, staticFile(name))
+			return
+		}
+		a := assets[name]
+		etag := `W/"` + a.hash + `"`
+		h.Set("ETag", etag)
+		h.Set("Vary", "Accept-Encoding")
+		h.Set("Content-Type", a.contentType)
+		// Une URL sans empreinte (ou d'une version précédente) est revalidée à chaque visite
+		if r.URL.Query().Get("v") == a.hash {
+			h.Set("Cache-Control", immutableCache)
+		} else {
+			h.Set("Cache-Control", "no-cache")
+		}
+		if match := r.Header.Get("If-None-Match"); match == "*" || strings.Contains(match, `"`+a.hash+`"`) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		body := a.body
+		if a.gzipped != nil && acceptsEncoding(r, "gzip") {
+			h.Set("Content-Encoding", "gzip")
+			body = a.gzipped
+		}
+		h.Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method != "HEAD" {
+			w.Write(body)
+		}
+	}
+}
+
+// Fonctions des templates : asset "style.css" donne l'URL versionnée d'un fichier statique.
+var templateFuncs = template.FuncMap{"asset": assetURL}
+
+// templateFS retourne les templates embarqués, ou le dossier server.templates en mode -dev.
+func templateFS() fs.FS {
+	if config.Server.Dev {
+		return os.DirFS(config.Server.Templates)
+	}
+	sub, _ := fs.Sub(embeddedFiles, "templates") // Dossier embarqué : pas d'erreur possible
+	return sub
+}
+
+// parseTemplate lit le template de page name.
+func parseTemplate(name string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).ParseFS(templateFS(), name)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"power4/engine"
+)
+
+// Images du plateau (SVG et PNG), à intégrer dans une discussion ou une documentation.
+// Tout est dessiné avec la bibliothèque standard, à partir de la même description du plateau.
+
+// Dimensions des images, en pixels.
+const (
+	imgCell   = 56 // Pas de la grille
+	imgPad    = 14 // Marge autour de la grille
+	imgRadius = 22 // Rayon d'un jeton
+	imgStroke = 3  // Épaisseur du contour des jetons
+)
+
+// tokenStyle est l'apparence d'un jeton : remplissage et contour.
+type tokenStyle struct {
+	Fill, Stroke string
+}
+
+// boardPalette regroupe les couleurs d'un skin.
+type boardPalette struct {
+	Background string // Fond de l'image
+	Board      string // Plateau
+	Frame      string // Bordure du plateau
+	Hole       string // Case vide
+	Highlight  string // Anneau des jetons gagnants
+	Marker     string // Marque du dernier coup
+	Tokens     map[string]tokenStyle
+}
+
+// Couleurs des jetons communes à tous les skins (celles de style.css).
+var defaultTokens = map[string]tokenStyle{
+	"red":    {"#c44536", "#ffeccc"},
+	"yellow": {"#ffe066", "#ffeccc"},
+	"green":  {"#4caf50", "#ffeccc"},
+	"blue":   {"#3f8efc", "#ffeccc"},
+	"purple": {"#9b59b6", "#ffeccc"},
+	"orange": {"#ff8c42", "#ffeccc"},
+}
+
+var obstacleStyle = tokenStyle{"#3a4a5c", "#5c6b7d"}
+
+// Palettes des skins ; les jetons absents reprennent defaultTokens.
+var boardPalettes = map[string]boardPalette{
+	"classic": {
+		Background: "#0d1b2a", Board: "#1e3a5c", Frame: "#274472", Hole: "#0d1b2a",
+		Highlight: "#ffe066", Marker: "#ffffff",
+	},
+	"neon": {
+		Background: "#000000", Board: "#0d0d0d", Frame: "#00ffcc", Hole: "#1a1a1a",
+		Highlight: "#00ffcc", Marker: "#ffffff",
+		Tokens: map[string]tokenStyle{"red": {"#ff0066", "#ff99cc"}, "yellow": {"#ffcc00", "#ffff99"}},
+	},
+	"retro": {
+		Background: "#1f1717", Board: "#3b2f2f", Frame: "#9e4b47", Hole: "#2a2020",
+		Highlight: "#fbe8c6", Marker: "#ffffff",
+		Tokens: map[string]tokenStyle{"red": {"#d9534f", "#f7c6c6"}, "yellow": {"#f0ad4e", "#fbe8c6"}},
+	},
+}
+
+// paletteFor retourne la palette d'un skin, classique par défaut.
+func paletteFor(skin string) boardPalette {
+	if p, ok := boardPalettes[skin]; ok {
+		return p
+	}
+	return boardPalettes["classic"]
+}
+
+// token retourne l'apparence des jetons d'une couleur dans ce skin.
+func (p boardPalette) token(color string) tokenStyle {
+	if s, ok := p.Tokens[color]; ok {
+		return s
+	}
+	return defaultTokens[color]
+}
+
+// imageCell est une case du plateau à dessiner.
+type imageCell struct {
+	X, Y    int // Centre, en pixels
+	Value   int // Contenu de la case (0, joueur ou engine.Obstacle)
+	Style   tokenStyle
+	Winning bool
+	Last    bool
+}
+
+// imageSize retourne la taille de l'image d'un plateau.
+func imageSize(g *Game) (int, int) {
+	return g.Cols*imgCell + 2*imgPad, g.Rows*imgCell + 2*imgPad
+}
+
+// boardCells décrit toutes les cases du plateau, avec le dernier coup et, si highlight est
+// vrai, les jetons gagnants.
+func boardCells(g *Game, palette boardPalette, highlight bool) []imageCell {
+	winning := map[[2]int]bool{}
+	if highlight && g.GameOver && g.Winner != 0 {
+		for _, pos := range g.WinningPositions() {
+			winning[pos] = true
+		}
+	}
+	cells := make([]imageCell, 0, g.Rows*g.Cols)
+	for r := 0; r < g.Rows; r++ {
+		for c := 0; c < g.Cols; c++ {
+			cell := imageCell{
+				X:       imgPad + c*imgCell + imgCell/2,
+				Y:       imgPad + r*imgCell + imgCell/2,
+				Value:   g.Board[r][c],
+				Winning: winning[[2]int{r, c}],
+				Last:    r == g.LastRow && c == g.LastCol,
+			}
+			switch {
+			case cell.Value > 0:
+				cell.Style = palette.token(g.colorOf(cell.Value))
+			case cell.Value == engine.Obstacle:
+				cell.Style = obstacleStyle
+			}
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// renderSVG dessine le plateau en SVG.
+func renderSVG(g *Game, skin string) []byte {
+	palette := paletteFor(skin)
+	w, h := imageSize(g)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", w, h, w, h)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`+"\n", w, h, palette.Background)
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="16" fill="%s" stroke="%s" stroke-width="4"/>`+"\n",
+		imgPad/2, imgPad/2, w-imgPad, h-imgPad, palette.Board, palette.Frame)
+	for _, c := range boardCells(g, palette, true) {
+		if c.Winning {
+			fmt.Fprintf(&b, `<circle cx="%d" cy="%d" r="%d" fill="none" stroke="%s" stroke-width="4"/>`+"\n",
+				c.X, c.Y, imgRadius+5, palette.Highlight)
+		}
+		switch {
+		case c.Value == 0:
+			fmt.Fprintf(&b, `<circle cx="%d" cy="%d" r="%d" fill="%s"/>`+"\n", c.X, c.Y, imgRadius, palette.Hole)
+		case c.Value == engine.Obstacle:
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="8" fill="%s" stroke="%s" stroke-width="%d"/>`+"\n",
+				c.X-imgRadius, c.Y-imgRadius, 2*imgRadius, 2*imgRadius, c.Style.Fill, c.Style.Stroke, imgStroke)
+		default:
+			fmt.Fprintf(&b, `<circle cx="%d" cy="%d" r="%d" fill="%s" stroke="%s" stroke-width="%d"/>`+"\n",
+				c.X, c.Y, imgRadius-imgStroke/2, c.Style.Fill, c.Style.Stroke, imgStroke)
+		}
+		if c.Last {
+			fmt.Fprintf(&b, `<circle cx="%d" cy="%d" r="6" fill="%s" fill-opacity="0.85"/>`+"\n", c.X, c.Y, palette.Marker)
+		}
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+// hexColor convertit une couleur "#rrggbb" en couleur opaque.
+func hexColor(s string) color.RGBA {
+	v, _ := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+}
+
+// blend mélange la couleur c au pixel (x, y) avec une opacité alpha entre 0 et 1.
+func blend(img *image.RGBA, x, y int, c color.RGBA, alpha float64) {
+	if alpha <= 0 || !(image.Point{x, y}).In(img.Rect) {
+		return
+	}
+	alpha = math.Min(alpha, 1)
+	dst := img.RGBAAt(x, y)
+	mix := func(a, b uint8) uint8 { return uint8(float64(a)*(1-alpha) + float64(b)*alpha + 0.5) }
+	img.SetRGBA(x, y, color.RGBA{mix(dst.R, c.R), mix(dst.G, c.G), mix(dst.B, c.B), 255})
+}
+
+// fillCircle remplit un disque, avec un bord lissé sur un pixel.
+func fillCircle(img *image.RGBA, cx, cy, r float64, c color.RGBA) {
+	for y := int(cy - r - 1); y <= int(cy+r+1); y++ {
+		for x := int(cx - r - 1); x <= int(cx+r+1); x++ {
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+			blend(img, x, y, c, r+0.5-d)
+		}
+	}
+}
+
+// strokeCircle trace un cercle d'épaisseur width.
+func strokeCircle(img *image.RGBA, cx, cy, r, width float64, c color.RGBA) {
+	for y := int(cy - r - width); y <= int(cy+r+width); y++ {
+		for x := int(cx - r - width); x <= int(cx+r+width); x++ {
+			d := math.Abs(math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) - r)
+			blend(img, x, y, c, width/2+0.5-d)
+		}
+	}
+}
+
+// fillRect remplit un rectangle.
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	rect = rect.Intersect(img.Rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// paintBoard dessine le plateau en image matricielle.
+func paintBoard(g *Game, skin string) *image.RGBA {
+	return paintFrame(g, paletteFor(skin), true)
+}
+
+// paintFrame dessine le plateau avec une palette donnée ; highlight marque les jetons gagnants.
+func paintFrame(g *Game, palette boardPalette, highlight bool) *image.RGBA {
+	w, h := imageSize(g)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	fillRect(img, img.Rect, hexColor(palette.Background))
+	fillRect(img, image.Rect(imgPad/2-2, imgPad/2-2, w-imgPad/2+2, h-imgPad/2+2), hexColor(palette.Frame))
+	fillRect(img, image.Rect(imgPad/2+2, imgPad/2+2, w-imgPad/2-2, h-imgPad/2-2), hexColor(palette.Board))
+	for _, c := range boardCells(g, palette, highlight) {
+		x, y := float64(c.X), float64(c.Y)
+		if c.Winning {
+			strokeCircle(img, x, y, imgRadius+5, 4, hexColor(palette.Highlight))
+		}
+		switch {
+		case c.Value == 0:
+			fillCircle(img, x, y, imgRadius, hexColor(palette.Hole))
+		case c.Value == engine.Obstacle:
+			fillRect(img, image.Rect(c.X-imgRadius, c.Y-imgRadius, c.X+imgRadius, c.Y+imgRadius), hexColor(c.Style.Stroke))
+			fillRect(img, image.Rect(c.X-imgRadius+imgStroke, c.Y-imgRadius+imgStroke, c.X+imgRadius-imgStroke, c.Y+imgRadius-imgStroke), hexColor(c.Style.Fill))
+		default:
+			fillCircle(img, x, y, imgRadius, hexColor(c.Style.Stroke))
+			fillCircle(img, x, y, imgRadius-imgStroke, hexColor(c.Style.Fill))
+		}
+		if c.Last {
+			marker := hexColor(palette.Marker)
+			fillCircle(img, x, y, 6, marker)
+		}
+	}
+	return img
+}
+
+// boardImageHandler sert /board.svg et /board.png : la position d'une partie (?game=ID) ou
+// une position donnée en notation (?moves=4453&difficulty=easy&mode=normal). Le paramètre
+// skin choisit les couleurs (classic, neon ou retro).
+func boardImageHandler(w http.ResponseWriter, r *http.Request) {
+	if !acquireRender(w, r) {
+		return
+	}
+	defer releaseRender()
+	q := r.URL.Query()
+	var body []byte
+	if id := q.Get("game"); id != "" {
+		mutex.Lock()
+		g := games[id]
+		if g == nil {
+			mutex.Unlock()
+			http.Error(w, "Partie introuvable", http.StatusNotFound)
+			return
+		}
+		skin := q.Get("skin")
+		if skin == "" {
+			skin = g.Skin
+		}
+		// L'image est produite hors du verrou global, sur une copie de la position
+		g = g.boardSnapshot()
+		mutex.Unlock()
+		body = encodeBoardImage(g, skin, r.URL.Path)
+		// La position d'une partie en cours change à chaque coup
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		g, err := positionFromQuery(q)
+		if err != nil {
+			http.Error(w, "Position invalide : "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = encodeBoardImage(g, q.Get("skin"), r.URL.Path)
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+	}
+	if strings.HasSuffix(r.URL.Path, ".svg") {
+		w.Header().Set("Content-Type", "image/svg+xml")
+	} else {
+		w.Header().Set("Content-Type", "image/png")
+	}
+	w.Write(body)
+}
+
+// boardSnapshot copie la partie pour la dessiner sans retenir le verrou global. Doit être
+// appelé avec mutex verrouillé.
+func (g *Game) boardSnapshot() *Game {
+	snap := *g
+	snap.Game = *g.Game.Clone()
+	snap.Usernames = append([]string(nil), g.Usernames...)
+	snap.Colors = append([]string(nil), g.Colors...)
+	return &snap
+}
+
+// encodeBoardImage produit l'image au format demandé par l'extension du chemin.
+func encodeBoardImage(g *Game, skin, path string) []byte {
+	if strings.HasSuffix(path, ".svg") {
+		return renderSVG(g, skin)
+	}
+	var b bytes.Buffer
+	png.Encode(&b, paintBoard(g, skin))
+	return b.Bytes()
+}
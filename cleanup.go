@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Ménage de la mémoire du serveur, une fois par minute : les sessions expirées ou, pour les
+// invités, inactives sont oubliées (et retirées de la base pour les joueurs connectés), les
+// parties terminées le sont après un délai qui laisse consulter leur bilan, les parties sans
+// coup depuis longtemps sont considérées comme abandonnées. Les parties en cours d'un tournoi restent : le tableau les
+// attend, et le tournoi est lui-même conservé.
+
+const (
+	sweepInterval   = time.Minute
+	finishedGameTTL = 6 * time.Hour  // Bilan et revanche d'une partie terminée
+	idleGameTTL     = 48 * time.Hour // Partie sans aucun changement : abandonnée
+)
+
+// lastActive retourne le dernier changement de la partie.
+func (g *Game) lastActive() time.Time {
+	if g.Active.After(g.Started) {
+		return g.Active
+	}
+	return g.Started
+}
+
+// abandoned indique si la partie, en cours, n'a pas bougé depuis idleGameTTL.
+func (g *Game) abandoned(now time.Time) bool {
+	return !g.GameOver && g.Tournament == "" && now.Sub(g.lastActive()) > idleGameTTL
+}
+
+// expired indique si la partie peut être oubliée : terminée depuis finishedGameTTL, ou abandonnée.
+func (g *Game) expired(now time.Time) bool {
+	if g.GameOver {
+		ended := g.Ended
+		if ended.IsZero() {
+			ended = g.lastActive()
+		}
+		return now.Sub(ended) > finishedGameTTL
+	}
+	return g.abandoned(now)
+}
+
+// sweepState oublie les sessions expirées et les parties terminées ou abandonnées, et ferme
+// les flux d'événements qui les suivaient encore. Doit être appelé avec mutex verrouillé.
+func sweepState(now time.Time) (evictedSessions, evictedGames int) {
+	var stored []string
+	for id, s := range sessions {
+		if s.expired(now) {
+			delete(sessions, id)
+			evictedSessions++
+			if s.User != "" {
+				stored = append(stored, id)
+			}
+		}
+	}
+	if len(stored) > 0 {
+		err := store.Update(func(d *storeData) error {
+			for _, id := range stored {
+				delete(d.Sessions, id)
+			}
+			return nil
+		})
+		if err != nil {
+			slog.Error("suppression des sessions expirées", "err", err)
+		}
+	}
+	for id, g := range games {
+		if g.expired(now) {
+			delete(games, id)
+			evictedGames++
+			// Les flux encore ouverts se ferment en ne trouvant plus la partie
+			for ch := range gameWatchers[id] {
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+	return evictedSessions, evictedGames
+}
+
+// sweepLoop fait le ménage toutes les sweepInterval, pendant toute la vie du serveur.
+func sweepLoop() {
+	for now := range time.Tick(sweepInterval) {
+		mutex.Lock()
+		s, g := sweepState(now)
+		mutex.Unlock()
+		if s > 0 || g > 0 {
+			slog.Info("ménage de la mémoire", "sessions", s, "games", g)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import "time"
+
+// aiDelay retourne le délai d'affichage avant le coup de l'IA, sans jamais consommer
+// plus d'un vingtième de son temps restant.
+func (g *Game) aiDelay() int {
+	if g.Clock == nil {
+		return tuning.AIDelayMs
+	}
+	return min(tuning.AIDelayMs, int(g.Remaining(g.CurrentPlayer)/time.Millisecond)/20)
+}
+
+// clockInfo décrit la pendule d'un joueur pour la page de jeu.
+type clockInfo struct {
+	Name        string
+	Color       string
+	RemainingMs int64
+	Running     bool
+}
+
+// clockInfos retourne l'état des pendules, ou nil pour une partie sans cadence.
+func (g *Game) clockInfos() []clockInfo {
+	if g.Clock == nil {
+		return nil
+	}
+	infos := make([]clockInfo, 0, g.Players)
+	for p := 1; p <= g.Players; p++ {
+		infos = append(infos, clockInfo{
+			Name:        g.playerName(p),
+			Color:       g.colorOf(p),
+			RemainingMs: g.Remaining(p).Milliseconds(),
+			Running:     p == g.CurrentPlayer && !g.GameOver,
+		})
+	}
+	return infos
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// dataPath retourne l'emplacement de la base (storage.data dans la configuration).
+func dataPath() string {
+	return config.Storage.Data
+}
+
+// command est une sous-commande de l'exécutable, lancée à la place du serveur.
+type command struct {
+	help string
+	run  func(args []string) error
+}
+
+var commands = map[string]command{
+	"admin":             {"donne à un compte l'accès à /admin (-revoke pour le retirer)", adminCommand},
+	"gif":               {"exporte une partie en GIF animé (-game ID ou -moves 4453, -o fichier)", gifCommand},
+	"recompute-ratings": {"recalcule tous les classements Elo à partir de l'historique des parties", recomputeRatingsCommand},
+	"tui":               {"joue une partie dans le terminal (-ai easy|medium|hard, -mode, -difficulty)", tuiCommand},
+}
+
+// runCommand exécute la sous-commande args[0] et retourne le code de sortie.
+func runCommand(args []string) int {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "commande inconnue : %s\n\nUsage : power4 [commande]\n", args[0])
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].help)
+		}
+		return 2
+	}
+	// Configuration du fichier et des variables d'environnement, les drapeaux étant ceux de la commande
+	cfg, err := loadConfig(nil, false)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	applyConfig(cfg)
+	if err := cmd.run(args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Erreur :", err)
+		return 1
+	}
+	return 0
+}
+
+// recomputeRatingsCommand rejoue tout l'historique pour appliquer la formule de classement actuelle.
+// À lancer serveur arrêté : le serveur réécrirait sinon la base avec ses propres classements.
+func recomputeRatingsCommand(args []string) error {
+	s, err := OpenStore(dataPath())
+	if err != nil {
+		return err
+	}
+	var rated, players int
+	err = s.Update(func(d *storeData) error {
+		recomputeRatings(d)
+		for _, rec := range d.Results {
+			if rec.RatingChanges != nil {
+				rated++
+			}
+		}
+		players = len(d.Ratings)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d parties classées rejouées, %d joueurs classés\n", rated, players)
+	return nil
+}
+
+// adminCommand donne ou retire le droit d'administration d'un compte. Comme recompute-ratings,
+// à lancer serveur arrêté.
+func adminCommand(args []string) error {
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	revoke := fs.Bool("revoke", false, "retire le droit au lieu de le donner")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("indiquer un nom de compte")
+	}
+	name := fs.Arg(0)
+	s, err := OpenStore(dataPath())
+	if err != nil {
+		return err
+	}
+	err = s.Update(func(d *storeData) error {
+		u := d.Users[strings.ToLower(name)]
+		if u == nil {
+			return fmt.Errorf("compte %s introuvable", name)
+		}
+		u.Admin = !*revoke
+		name = u.Name
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if *revoke {
+		fmt.Printf("%s n'a plus accès à /admin\n", name)
+	} else {
+		fmt.Printf("%s a accès à /admin\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"power4/engine"
+)
+
+// Configuration du serveur. Chaque réglage prend, par ordre de priorité, la valeur du drapeau
+// de la ligne de commande, de la variable d'environnement, du fichier de configuration (JSON,
+// désigné par -config ou POWER4_CONFIG), puis sa valeur par défaut. La configuration est
+// validée au démarrage : le serveur refuse de démarrer en listant les réglages invalides.
+//
+//	Fichier                     Variable                    Drapeau       Défaut
+//	server.port                 PORT                        -port         8080
+//	server.tls_cert             POWER4_TLS_CERT             -tls-cert
+//	server.tls_key              POWER4_TLS_KEY              -tls-key
+//	server.trust_proxy          POWER4_TRUST_PROXY
+//	server.public_url           POWER4_PUBLIC_URL           -public-url   adresse de la requête
+//	server.log                  POWER4_LOG                  -log          text (ou json)
+//	server.dev                  POWER4_DEV                  -dev          fichiers du site relus sur le disque
+//	server.templates            POWER4_TEMPLATES            -templates    templates (mode -dev)
+//	server.static               POWER4_STATIC               -static       . (mode -dev : style.css, favicon.svg, scripts)
+//	limits.rate_ip              POWER4_RATE_IP                            300
+//	limits.rate_session         POWER4_RATE_SESSION                       120
+//	limits.rate_new_games       POWER4_RATE_NEW_GAMES                     20
+//	limits.ai_concurrency       POWER4_AI_CONCURRENCY                     nombre de processeurs
+//	limits.render_concurrency   POWER4_RENDER_CONCURRENCY                 nombre de processeurs
+//	limits.max_streams          POWER4_MAX_STREAMS                        8
+//	limits.max_body             POWER4_MAX_BODY                           65536
+//	game.presets                                                          plateaux easy, normal et hard
+//	game.layouts                POWER4_LAYOUTS              -layouts      layouts (mode -dev)
+//	ai.delay_ms                 POWER4_AI_DELAY_MS          -ai-delay     1000
+//	ai.depth                    POWER4_AI_DEPTH             -ai-depth     4
+//	storage.data                POWER4_DATA                 -data         data/power4.json
+
+// Config regroupe les réglages du serveur.
+type Config struct {
+	Server  ServerConfig  `json:"server"`
+	Limits  LimitsConfig  `json:"limits"`
+	Game    GameConfig    `json:"game"`
+	AI      AIConfig      `json:"ai"`
+	Storage StorageConfig `json:"storage"`
+}
+
+// ServerConfig règle le serveur HTTP.
+type ServerConfig struct {
+	Port       int    `json:"port"`
+	TLSCert    string `json:"tls_cert"` // Certificat et clé : HTTPS si les deux sont donnés
+	TLSKey     string `json:"tls_key"`
+	TrustProxy bool   `json:"trust_proxy"` // Adresse du client lue dans X-Forwarded-For
+	PublicURL  string `json:"public_url"`  // Adresse du site dans les liens à partager (https://exemple.fr)
+	Log        string `json:"log"`         // Format du journal : text ou json
+	Dev        bool   `json:"dev"`         // Fichiers du site relus sur le disque plutôt qu'embarqués
+	Templates  string `json:"templates"`   // Dossier des templates HTML, en mode dev
+	Static     string `json:"static"`      // Dossier de style.css, favicon.svg et des scripts, en mode dev
+}
+
+// LimitsConfig règle les protections contre les abus (voir ratelimit.go) ; 0 désactive une limite.
+type LimitsConfig struct {
+	RateIP            int   `json:"rate_ip"`
+	RateSession       int   `json:"rate_session"`
+	RateNewGames      int   `json:"rate_new_games"`
+	AIConcurrency     int   `json:"ai_concurrency"`
+	RenderConcurrency int   `json:"render_concurrency"` // Rendus d'images (PNG, SVG, GIF) simultanés
+	MaxStreams        int   `json:"max_streams"`        // Flux d'événements ouverts par adresse IP
+	MaxBody           int64 `json:"max_body"`
+}
+
+// GameConfig règle les plateaux proposés.
+type GameConfig struct {
+	Presets map[string]boardPreset `json:"presets"` // Par difficulté : easy, normal, hard
+	Layouts string                 `json:"layouts"` // Dossier des plateaux écrits à la main, en mode dev
+}
+
+// AIConfig règle l'IA.
+type AIConfig struct {
+	DelayMs int `json:"delay_ms"` // Délai avant le coup de l'IA, modifiable ensuite depuis /admin
+	Depth   int `json:"depth"`    // Profondeur de recherche de l'IA difficile
+}
+
+// StorageConfig règle la base.
+type StorageConfig struct {
+	Data string `json:"data"` // Fichier de la base
+}
+
+// Difficultés proposées, dans l'ordre des menus, et leur nom.
+var difficulties = []string{"easy", "normal", "hard"}
+
+var difficultyNames = map[string]string{"easy": "Facile", "normal": "Normal", "hard": "Difficile"}
+
+// Bornes des réglages.
+const (
+	minPresetSize = 4
+	maxPresetSize = 20
+	maxAIDepth    = 6 // Au-delà, une recherche sur le grand plateau bloque un processeur plusieurs secondes
+)
+
+// config est la configuration en vigueur, chargée au démarrage.
+var config = defaultConfig()
+
+// defaultConfig retourne la configuration par défaut.
+func defaultConfig() Config {
+	return Config{
+		Server: ServerConfig{Port: 8080, Log: "text", Templates: "templates", Static: "."},
+		Limits: LimitsConfig{RateIP: 300, RateSession: 120, RateNewGames: 20, AIConcurrency: runtime.NumCPU(),
+			RenderConcurrency: runtime.NumCPU(), MaxStreams: 8, MaxBody: 64 << 10},
+		Game: GameConfig{
+			// Le preset difficile a un nombre de jetons pair pour que personne ne commence
+			// avec un jeton d'avance.
+			Presets: map[string]boardPreset{
+				"easy":   {Rows: 6, Cols: 7},
+				"normal": {Rows: 7, Cols: 8},
+				"hard":   {Rows: 8, Cols: 10, Prefill: 6, Obstacles: 3},
+			},
+			Layouts: "layouts",
+		},
+		AI:      AIConfig{DelayMs: 1000, Depth: engine.DefaultMaxDepth},
+		Storage: StorageConfig{Data: "data/power4.json"},
+	}
+}
+
+// configProblems accumule les erreurs de configuration, pour les signaler toutes à la fois.
+type configProblems []string
+
+func (p *configProblems) add(format string, args ...any) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+func (p configProblems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return errors.New("configuration invalide :\n  - " + strings.Join(p, "\n  - "))
+}
+
+// loadConfig charge la configuration : fichier, variables d'environnement puis drapeaux args.
+// Pour le serveur, les fichiers et dossiers qu'il sert doivent aussi exister ; les
+// sous-commandes n'ont besoin que de la base et des plateaux.
+func loadConfig(args []string, server bool) (Config, error) {
+	c := defaultConfig()
+	var problems configProblems
+
+	// Les drapeaux sont lus d'abord (ils désignent le fichier) mais appliqués en dernier
+	fs := flag.NewFlagSet("power4", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("POWER4_CONFIG"), "fichier de configuration JSON")
+	var flags []func()
+	stringFlag := func(name, usage string, dst *string) {
+		fs.Func(name, usage, func(v string) error {
+			flags = append(flags, func() { *dst = v })
+			return nil
+		})
+	}
+	boolFlag := func(name, usage string, dst *bool) {
+		fs.BoolFunc(name, usage, func(v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return errors.New("true ou false attendu")
+			}
+			flags = append(flags, func() { *dst = b })
+			return nil
+		})
+	}
+	intFlag := func(name, usage string, dst *int) {
+		fs.Func(name, usage, func(v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return errors.New("nombre entier attendu")
+			}
+			flags = append(flags, func() { *dst = n })
+			return nil
+		})
+	}
+	intFlag("port", "port d'écoute", &c.Server.Port)
+	stringFlag("tls-cert", "certificat TLS", &c.Server.TLSCert)
+	stringFlag("tls-key", "clé du certificat TLS", &c.Server.TLSKey)
+	stringFlag("public-url", "adresse du site dans les liens à partager", &c.Server.PublicURL)
+	stringFlag("log", "format du journal (text ou json)", &c.Server.Log)
+	boolFlag("dev", "relit templates, fichiers statiques et plateaux sur le disque (édition en direct)", &c.Server.Dev)
+	stringFlag("templates", "dossier des templates HTML (mode -dev)", &c.Server.Templates)
+	stringFlag("static", "dossier des fichiers statiques (mode -dev)", &c.Server.Static)
+	stringFlag("layouts", "dossier des plateaux personnalisés (mode -dev)", &c.Game.Layouts)
+	intFlag("ai-delay", "délai avant le coup de l'IA (ms)", &c.AI.DelayMs)
+	intFlag("ai-depth", "profondeur de recherche de l'IA difficile", &c.AI.Depth)
+	stringFlag("data", "fichier de la base", &c.Storage.Data)
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+	if fs.NArg() > 0 {
+		return c, fmt.Errorf("argument inattendu : %s", fs.Arg(0))
+	}
+
+	if *path != "" {
+		if err := readConfigFile(*path, &c); err != nil {
+			problems.add("%v", err)
+		}
+	}
+	c.applyEnv(&problems)
+	for _, apply := range flags {
+		apply()
+	}
+	c.validate(&problems, server)
+	return c, problems.err()
+}
+
+// readConfigFile lit le fichier de configuration par-dessus c. Les presets du fichier
+// remplacent ceux de même difficulté, les autres gardent leur valeur par défaut.
+func readConfigFile(path string, c *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("fichier de configuration : %v", err)
+	}
+	defer f.Close()
+	presets := c.Game.Presets
+	c.Game.Presets = nil
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("fichier de configuration %s : %v", path, err)
+	}
+	for difficulty, p := range c.Game.Presets {
+		presets[difficulty] = p
+	}
+	c.Game.Presets = presets
+	return nil
+}
+
+// applyEnv applique les variables d'environnement définies.
+func (c *Config) applyEnv(problems *configProblems) {
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	num := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				problems.add("%s : nombre entier attendu, %q reçu", name, v)
+				return
+			}
+			*dst = n
+		}
+	}
+	boolean := func(name string, dst *bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			switch v {
+			case "1", "true":
+				*dst = true
+			case "0", "false", "":
+				*dst = false
+			default:
+				problems.add("%s : 1 ou 0 attendu, %q reçu", name, v)
+			}
+		}
+	}
+	num("PORT", &c.Server.Port)
+	str("POWER4_TLS_CERT", &c.Server.TLSCert)
+	str("POWER4_TLS_KEY", &c.Server.TLSKey)
+	boolean("POWER4_TRUST_PROXY", &c.Server.TrustProxy)
+	str("POWER4_PUBLIC_URL", &c.Server.PublicURL)
+	boolean("POWER4_DEV", &c.Server.Dev)
+	str("POWER4_LOG", &c.Server.Log)
+	str("POWER4_TEMPLATES", &c.Server.Templates)
+	str("POWER4_STATIC", &c.Server.Static)
+	num("POWER4_RATE_IP", &c.Limits.RateIP)
+	num("POWER4_RATE_SESSION", &c.Limits.RateSession)
+	num("POWER4_RATE_NEW_GAMES", &c.Limits.RateNewGames)
+	num("POWER4_AI_CONCURRENCY", &c.Limits.AIConcurrency)
+	num("POWER4_RENDER_CONCURRENCY", &c.Limits.RenderConcurrency)
+	num("POWER4_MAX_STREAMS", &c.Limits.MaxStreams)
+	maxBody := int(c.Limits.MaxBody)
+	num("POWER4_MAX_BODY", &maxBody)
+	c.Limits.MaxBody = int64(maxBody)
+	str("POWER4_LAYOUTS", &c.Game.Layouts)
+	num("POWER4_AI_DELAY_MS", &c.AI.DelayMs)
+	num("POWER4_AI_DEPTH", &c.AI.Depth)
+	str("POWER4_DATA", &c.Storage.Data)
+}
+
+// validate vérifie chaque réglage, et les fichiers du serveur si server est vrai.
+func (c *Config) validate(problems *configProblems, server bool) {
+	s := &c.Server
+	if s.Port < 1 || s.Port > 65535 {
+		problems.add("server.port : %d n'est pas un port (1 à 65535)", s.Port)
+	}
+	if (s.TLSCert == "") != (s.TLSKey == "") {
+		problems.add("server.tls_cert et server.tls_key : le certificat et sa clé vont ensemble")
+	}
+	if u, err := url.Parse(s.PublicURL); s.PublicURL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		problems.add("server.public_url : %q n'est pas une adresse http:// ou https://", s.PublicURL)
+	}
+	if s.Log != "text" && s.Log != "json" {
+		problems.add("server.log : %q inconnu (text ou json)", s.Log)
+	}
+	if server {
+		for _, file := range []struct{ field, path string }{{"server.tls_cert", s.TLSCert}, {"server.tls_key", s.TLSKey}} {
+			if _, err := os.Stat(file.path); file.path != "" && err != nil {
+				problems.add("%s : fichier %q introuvable", file.field, file.path)
+			}
+		}
+	}
+	if server && s.Dev {
+		for _, dir := range []struct{ field, path string }{
+			{"server.templates", s.Templates}, {"server.static", s.Static}, {"game.layouts", c.Game.Layouts},
+		} {
+			if info, err := os.Stat(dir.path); err != nil || !info.IsDir() {
+				problems.add("%s : dossier %q introuvable", dir.field, dir.path)
+			}
+		}
+	}
+
+	l := &c.Limits
+	for _, rate := range []struct {
+		field string
+		n     int
+	}{{"limits.rate_ip", l.RateIP}, {"limits.rate_session", l.RateSession}, {"limits.rate_new_games", l.RateNewGames},
+		{"limits.max_streams", l.MaxStreams}} {
+		if rate.n < 0 {
+			problems.add("%s : %d est négatif (0 désactive la limite)", rate.field, rate.n)
+		}
+	}
+	if l.AIConcurrency < 1 {
+		problems.add("limits.ai_concurrency : au moins une recherche de l'IA à la fois (%d)", l.AIConcurrency)
+	}
+	if l.RenderConcurrency < 1 {
+		problems.add("limits.render_concurrency : au moins un rendu d'image à la fois (%d)", l.RenderConcurrency)
+	}
+	if l.MaxBody < 0 {
+		problems.add("limits.max_body : %d est négatif (0 désactive la limite)", l.MaxBody)
+	}
+
+	for _, difficulty := range sortedKeys(c.Game.Presets) {
+		p := c.Game.Presets[difficulty]
+		field := "game.presets." + difficulty
+		if difficultyNames[difficulty] == "" {
+			problems.add("%s : difficulté inconnue (%s)", field, strings.Join(difficulties, ", "))
+			continue
+		}
+		if p.Rows < minPresetSize || p.Rows > maxPresetSize || p.Cols < minPresetSize || p.Cols > maxPresetSize {
+			problems.add("%s : plateau %dx%d hors limites (%d à %d lignes et colonnes)", field, p.Rows, p.Cols, minPresetSize, maxPresetSize)
+		}
+		if p.Prefill < 0 || p.Obstacles < 0 || 2*(p.Prefill+p.Obstacles) > p.Rows*p.Cols {
+			problems.add("%s : jetons et obstacles de départ doivent occuper au plus la moitié du plateau", field)
+		}
+	}
+
+	if c.AI.DelayMs < 0 || c.AI.DelayMs > maxAIDelayMs {
+		problems.add("ai.delay_ms : %d hors limites (0 à %d)", c.AI.DelayMs, maxAIDelayMs)
+	}
+	if c.AI.Depth < 1 || c.AI.Depth > maxAIDepth {
+		problems.add("ai.depth : %d hors limites (1 à %d)", c.AI.Depth, maxAIDepth)
+	}
+
+	if c.Storage.Data == "" {
+		problems.add("storage.data : fichier de la base manquant")
+	} else if info, err := os.Stat(filepath.Dir(c.Storage.Data)); err == nil && !info.IsDir() {
+		problems.add("storage.data : %s n'est pas un dossier", filepath.Dir(c.Storage.Data))
+	}
+}
+
+// applyConfig met la configuration en vigueur. À appeler au démarrage, avant de servir.
+func applyConfig(c Config) {
+	config = c
+	configureLimits(c.Limits, c.Server.TrustProxy)
+	publicURL = strings.TrimSuffix(c.Server.PublicURL, "/")
+	boardPresets = c.Game.Presets
+	for _, difficulty := range difficulties {
+		p := boardPresets[difficulty]
+		difficultyLabels[difficulty] = fmt.Sprintf("%s (%dx%d)", difficultyNames[difficulty], p.Rows, p.Cols)
+	}
+	tuning.AIDelayMs = c.AI.DelayMs
+}
+
+// presetOption est une difficulté proposée dans les menus.
+type presetOption struct {
+	Value, Label string
+}
+
+// presetOptions liste les difficultés pour les menus, avec la taille de leur plateau.
+func presetOptions() []presetOption {
+	options := make([]presetOption, len(difficulties))
+	for i, difficulty := range difficulties {
+		options[i] = presetOption{difficulty, difficultyLabels[difficulty]}
+	}
+	return options
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearConfigEnv retire les variables de configuration de l'environnement du test.
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"PORT", "POWER4_CONFIG", "POWER4_AI_DELAY_MS", "POWER4_AI_DEPTH", "POWER4_DATA", "POWER4_MAX_STREAMS", "POWER4_PUBLIC_URL"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+// writeConfigFile écrit un fichier de configuration temporaire et retourne son chemin.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "power4.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, `{
+		"server": {"port": 9000},
+		"limits": {"max_streams": 3},
+		"ai": {"delay_ms": 100, "depth": 2}
+	}`)
+	t.Setenv("POWER4_CONFIG", path)
+	t.Setenv("POWER4_AI_DELAY_MS", "200")
+	t.Setenv("POWER4_AI_DEPTH", "3")
+
+	c, err := loadConfig([]string{"-ai-depth", "5"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Port != 9000 || c.Limits.MaxStreams != 3 {
+		t.Errorf("fichier ignoré : port %d, max_streams %d", c.Server.Port, c.Limits.MaxStreams)
+	}
+	if c.AI.DelayMs != 200 {
+		t.Errorf("ai.delay_ms = %d, attendu 200 (variable d'environnement plutôt que fichier)", c.AI.DelayMs)
+	}
+	if c.AI.Depth != 5 {
+		t.Errorf("ai.depth = %d, attendu 5 (drapeau plutôt que variable et fichier)", c.AI.Depth)
+	}
+	if c.Limits.RateIP != defaultConfig().Limits.RateIP {
+		t.Errorf("limits.rate_ip = %d, attendu la valeur par défaut", c.Limits.RateIP)
+	}
+}
+
+func TestConfigFlagNamesFile(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("POWER4_CONFIG", writeConfigFile(t, `{"server": {"port": 9000}}`))
+	path := writeConfigFile(t, `{"server": {"port": 9100}}`)
+	c, err := loadConfig([]string{"-config", path}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Port != 9100 {
+		t.Errorf("port %d, attendu 9100 (fichier désigné par -config)", c.Server.Port)
+	}
+}
+
+func TestConfigReportsEveryProblem(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("POWER4_AI_DEPTH", "8")
+	t.Setenv("POWER4_MAX_STREAMS", "-1")
+	_, err := loadConfig([]string{"-port", "0"}, false)
+	if err == nil {
+		t.Fatal("configuration invalide acceptée")
+	}
+	for _, field := range []string{"ai.depth", "limits.max_streams", "server.port"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("%s absent de l'erreur : %v", field, err)
+		}
+	}
+}
+
+func TestConfigPublicURL(t *testing.T) {
+	clearConfigEnv(t)
+	c, err := loadConfig([]string{"-public-url", "https://power4.example.org/"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.PublicURL != "https://power4.example.org/" {
+		t.Errorf("server.public_url = %q", c.Server.PublicURL)
+	}
+	for _, bad := range []string{"power4.example.org", "ftp://power4.example.org", "https://"} {
+		if _, err := loadConfig([]string{"-public-url", bad}, false); err == nil || !strings.Contains(err.Error(), "server.public_url") {
+			t.Errorf("adresse %q acceptée (%v)", bad, err)
+		}
+	}
+}
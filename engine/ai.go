@@ -0,0 +1,295 @@
+package engine
+
+import "math/rand"
+
+// AILevel est le niveau de l'IA.
+type AILevel int
+
+const (
+	AIEasy   AILevel = iota // Coups au hasard
+	AIMedium                // Gagne ou bloque en un coup, sinon au hasard
+	AIHard                  // Minimax avec élagage alpha-beta
+)
+
+// Label retourne le nom affiché du niveau de l'IA.
+func (l AILevel) Label() string {
+	switch l {
+	case AIMedium:
+		return "Moyen"
+	case AIHard:
+		return "Difficile"
+	default:
+		return "Facile"
+	}
+}
+
+// String retourne le nom du niveau dans les paramètres de partie (ailevel=easy, medium, hard).
+func (l AILevel) String() string {
+	switch l {
+	case AIMedium:
+		return "medium"
+	case AIHard:
+		return "hard"
+	default:
+		return "easy"
+	}
+}
+
+// checkWinningMove vérifie si jouer à l'indice index ferait gagner le joueur
+func (g *Game) checkWinningMove(index, player int) bool {
+	// Simule le coup
+	row, col := g.Landing(index)
+	if row < 0 {
+		return false
+	}
+
+	// Place temporairement le jeton
+	g.Board[row][col] = player
+	win := g.CheckWin(row, col)
+	g.Board[row][col] = 0 // Retire le jeton
+
+	return win
+}
+
+// aiEasyMove - IA facile : joue aléatoirement
+func (g *Game) aiEasyMove() int {
+	moves := g.ValidMoves()
+	if len(moves) == 0 {
+		return -1
+	}
+	return moves[rand.Intn(len(moves))]
+}
+
+// aiMediumMove - IA moyenne : bloque les victoires adverses et cherche ses victoires
+func (g *Game) aiMediumMove() int {
+	moves := g.ValidMoves()
+	if len(moves) == 0 {
+		return -1
+	}
+
+	// 1. Cherche un coup gagnant pour l'IA (joueur 2)
+	for _, col := range moves {
+		if g.checkWinningMove(col, 2) {
+			return col
+		}
+	}
+
+	// 2. Bloque un coup gagnant de l'adversaire (joueur 1)
+	for _, col := range moves {
+		if g.checkWinningMove(col, 1) {
+			return col
+		}
+	}
+
+	// 3. Sinon, joue aléatoirement
+	return moves[rand.Intn(len(moves))]
+}
+
+// aiHardMove - IA difficile : utilise minimax
+func (g *Game) aiHardMove() int {
+	moves := g.ValidMoves()
+	if len(moves) == 0 {
+		return -1
+	}
+
+	// Utilise minimax avec une profondeur limitée (réduite si la pendule de l'IA est basse)
+	_, bestCol := g.minimax(g.aiSearchDepth(), true, -1000, 1000)
+
+	// Fallback au cas où minimax échoue
+	if bestCol == -1 && len(moves) > 0 {
+		return moves[0]
+	}
+
+	return bestCol
+}
+
+// minimax - Algorithme minimax avec élagage alpha-beta
+func (g *Game) minimax(depth int, isMaximizing bool, alpha, beta int) (int, int) {
+	// Conditions de fin
+	if depth == 0 || g.GameOver {
+		return g.evaluateBoard(), -1
+	}
+
+	moves := g.ValidMoves()
+	if len(moves) == 0 {
+		return 0, -1 // Match nul
+	}
+
+	bestCol := moves[0]
+
+	if isMaximizing {
+		maxEval := -1000
+		for _, index := range moves {
+			// Simule le coup
+			row, col, prev := g.simulateMove(index, 2)
+			if row == -1 {
+				continue
+			}
+
+			eval, _ := g.minimax(depth-1, false, alpha, beta)
+			g.undoMove(row, col, prev) // Annule le coup
+
+			if eval > maxEval {
+				maxEval = eval
+				bestCol = index
+			}
+
+			alpha = max(alpha, eval)
+			if beta <= alpha {
+				break // Élagage alpha-beta
+			}
+		}
+		return maxEval, bestCol
+	} else {
+		minEval := 1000
+		for _, index := range moves {
+			// Simule le coup
+			row, col, prev := g.simulateMove(index, 1)
+			if row == -1 {
+				continue
+			}
+
+			eval, _ := g.minimax(depth-1, true, alpha, beta)
+			g.undoMove(row, col, prev) // Annule le coup
+
+			if eval < minEval {
+				minEval = eval
+				bestCol = index
+			}
+
+			beta = min(beta, eval)
+			if beta <= alpha {
+				break // Élagage alpha-beta
+			}
+		}
+		return minEval, bestCol
+	}
+}
+
+// simulateMove simule un coup sans vérifier les conditions de victoire.
+// Comme DropToken, il avance le compteur de tours et fait tourner la gravité ;
+// il retourne la case occupée et la gravité précédente à passer à undoMove.
+func (g *Game) simulateMove(index, player int) (int, int, Gravity) {
+	prev := g.Gravity
+	row, col := g.Landing(index)
+	if row < 0 {
+		return -1, -1, prev
+	}
+
+	g.Board[row][col] = player
+	g.TurnCount++
+	g.advanceGravity()
+	return row, col, prev
+}
+
+// undoMove annule un coup joué par simulateMove
+func (g *Game) undoMove(row, col int, prev Gravity) {
+	g.Board[row][col] = 0
+	g.TurnCount--
+	g.Gravity = prev
+}
+
+// evaluateBoard évalue la position pour l'IA (joueur 2)
+func (g *Game) evaluateBoard() int {
+	score := 0
+
+	// Vérifie toutes les fenêtres de 4 cases
+	for r := 0; r < g.Rows; r++ {
+		for c := 0; c < g.Cols; c++ {
+			// Horizontal
+			if c+3 < g.Cols {
+				score += g.evaluateWindow(r, c, 0, 1)
+			}
+			// Vertical
+			if r+3 < g.Rows {
+				score += g.evaluateWindow(r, c, 1, 0)
+			}
+			// Diagonale descendante
+			if r+3 < g.Rows && c+3 < g.Cols {
+				score += g.evaluateWindow(r, c, 1, 1)
+			}
+			// Diagonale montante
+			if r+3 < g.Rows && c-3 >= 0 {
+				score += g.evaluateWindow(r, c, 1, -1)
+			}
+		}
+	}
+
+	return score
+}
+
+// evaluateWindow évalue une fenêtre de 4 cases
+func (g *Game) evaluateWindow(startR, startC, deltaR, deltaC int) int {
+	score := 0
+	aiCount := 0
+	humanCount := 0
+
+	for i := 0; i < 4; i++ {
+		r := startR + i*deltaR
+		c := startC + i*deltaC
+
+		if g.Board[r][c] == Obstacle {
+			// Un obstacle rend la fenêtre inutilisable pour tout le monde
+			return 0
+		}
+		if g.Board[r][c] == 2 {
+			aiCount++
+		} else if g.Board[r][c] > 0 {
+			// Tout jeton qui n'est pas à l'IA compte pour l'adversaire
+			humanCount++
+		}
+	}
+
+	// Ne peut pas être une ligne gagnante si les deux joueurs y ont des jetons
+	if aiCount > 0 && humanCount > 0 {
+		return 0
+	}
+
+	// Évaluation pour l'IA (joueur 2)
+	if aiCount == 4 {
+		score += 100
+	} else if aiCount == 3 {
+		score += 10
+	} else if aiCount == 2 {
+		score += 2
+	}
+
+	// Évaluation contre l'humain (joueur 1)
+	if humanCount == 4 {
+		score -= 100
+	} else if humanCount == 3 {
+		score -= 10
+	} else if humanCount == 2 {
+		score -= 2
+	}
+
+	return score
+}
+
+// AIMove choisit le coup de l'IA au niveau level. L'IA joue le joueur 2 : ses coups se jouent
+// ensuite avec DropToken. Retourne -1 s'il n'y a aucun coup jouable.
+func (g *Game) AIMove(level AILevel) int {
+	switch level {
+	case AIEasy:
+		return g.aiEasyMove()
+	case AIMedium:
+		return g.aiMediumMove()
+	case AIHard:
+		return g.aiHardMove()
+	default:
+		return g.aiEasyMove()
+	}
+}
+
+// SearchDepth retourne la profondeur de recherche de l'IA au niveau level dans la position
+// courante : 0 pour l'IA facile, 1 pour l'IA moyenne qui ne regarde qu'un coup à l'avance.
+func (g *Game) SearchDepth(level AILevel) int {
+	switch level {
+	case AIMedium:
+		return 1
+	case AIHard:
+		return g.aiSearchDepth()
+	default:
+		return 0
+	}
+}
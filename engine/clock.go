@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Horloge utilisée pour les pendules ; remplaçable pour les essais.
+var timeNow = time.Now
+
+// TimeControl décrit une cadence : temps initial par joueur et incrément ajouté après chaque coup.
+// La valeur zéro signifie une partie sans pendule.
+type TimeControl struct {
+	Base      time.Duration
+	Increment time.Duration
+}
+
+// ParseTimeControl lit une cadence au format "minutes+secondes" (ex. "3+2").
+// Une chaîne vide donne une partie sans pendule.
+func ParseTimeControl(s string) (TimeControl, error) {
+	if s == "" {
+		return TimeControl{}, nil
+	}
+	base, inc, ok := strings.Cut(s, "+")
+	if !ok {
+		inc = "0"
+	}
+	minutes, err1 := strconv.Atoi(base)
+	seconds, err2 := strconv.Atoi(inc)
+	if err1 != nil || err2 != nil || minutes < 1 || minutes > 60 || seconds < 0 || seconds > 60 {
+		return TimeControl{}, fmt.Errorf("cadence %q invalide", s)
+	}
+	return TimeControl{Base: time.Duration(minutes) * time.Minute, Increment: time.Duration(seconds) * time.Second}, nil
+}
+
+// String retourne la cadence au format "minutes+secondes", ou "" sans pendule.
+func (tc TimeControl) String() string {
+	if tc.Base == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(tc.Base/time.Minute)) + "+" + strconv.Itoa(int(tc.Increment/time.Second))
+}
+
+// Clock est la pendule d'une partie : le temps du joueur courant se décompte à chaque lecture.
+type Clock struct {
+	Remaining []time.Duration // Temps restant de chaque joueur au début de son tour, index 0 = joueur 1
+	TurnStart time.Time       // Début du tour du joueur courant
+}
+
+// StartClock met la pendule en route pour le premier joueur.
+func (g *Game) StartClock(tc TimeControl) {
+	g.TimeControl = tc
+	if tc.Base == 0 {
+		g.Clock = nil
+		return
+	}
+	remaining := make([]time.Duration, g.Players)
+	for i := range remaining {
+		remaining[i] = tc.Base
+	}
+	g.Clock = &Clock{Remaining: remaining, TurnStart: timeNow()}
+}
+
+// Remaining retourne le temps restant du joueur p à l'instant présent.
+func (g *Game) Remaining(p int) time.Duration {
+	if g.Clock == nil {
+		return 0
+	}
+	left := g.Clock.Remaining[p-1]
+	if p == g.CurrentPlayer && !g.GameOver {
+		left -= timeNow().Sub(g.Clock.TurnStart)
+	}
+	if left < 0 {
+		left = 0
+	}
+	return left
+}
+
+// punchClock arrête la pendule du joueur courant après son coup : on décompte le temps
+// passé, on ajoute l'incrément et on lance le tour suivant.
+func (g *Game) punchClock() {
+	if g.Clock == nil {
+		return
+	}
+	now := timeNow()
+	g.Clock.Remaining[g.CurrentPlayer-1] -= now.Sub(g.Clock.TurnStart)
+	g.Clock.Remaining[g.CurrentPlayer-1] += g.TimeControl.Increment
+	g.Clock.TurnStart = now
+}
+
+// CheckFlag constate la chute du drapeau du joueur courant : il perd la partie à deux,
+// il est éliminé à plusieurs. Retourne vrai si un drapeau est tombé.
+func (g *Game) CheckFlag() bool {
+	if g.Clock == nil || g.GameOver || g.Remaining(g.CurrentPlayer) > 0 {
+		return false
+	}
+	loser := g.CurrentPlayer
+	g.Clock.Remaining[loser-1] = 0
+	if g.Players <= 2 {
+		g.Winner = 3 - loser
+		g.GameOver = true
+		g.FlagFall = loser
+		return true
+	}
+	g.FlagFall = loser
+	g.Eliminate(loser)
+	g.Clock.TurnStart = timeNow()
+	return true
+}
+
+// DefaultMaxDepth est la profondeur de recherche de l'IA difficile quand le temps ne presse
+// pas, pour une partie qui ne fixe pas MaxDepth.
+const DefaultMaxDepth = 4
+
+// MaxSearchPositions borne la recherche de l'IA difficile : la profondeur est réduite tant que
+// la largeur du plateau élevée à la profondeur dépasse ce nombre de positions.
+const MaxSearchPositions = 1_000_000
+
+// boardDepthCap retourne la plus grande profondeur que MaxSearchPositions permet sur ce plateau.
+func (g *Game) boardDepthCap() int {
+	width := max(g.Rows, g.Cols, 2)
+	depth := 1
+	for positions := width; positions*width <= MaxSearchPositions; positions *= width {
+		depth++
+	}
+	return depth
+}
+
+// aiSearchDepth adapte la profondeur de recherche de l'IA à la taille du plateau et au temps
+// qui lui reste.
+func (g *Game) aiSearchDepth() int {
+	depth := g.MaxDepth
+	if depth <= 0 {
+		depth = DefaultMaxDepth
+	}
+	depth = min(depth, g.boardDepthCap())
+	if g.Clock == nil {
+		return depth
+	}
+	switch left := g.Remaining(g.CurrentPlayer); {
+	case left < 3*time.Second:
+		return min(1, depth)
+	case left < 10*time.Second:
+		return min(2, depth)
+	case left < 30*time.Second:
+		return min(3, depth)
+	default:
+		return depth
+	}
+}
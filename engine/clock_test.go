@@ -0,0 +1,21 @@
+package engine
+
+import "testing"
+
+func TestSearchDepthCappedByBoardWidth(t *testing.T) {
+	for _, tc := range []struct {
+		rows, cols, maxDepth, want int
+	}{
+		{6, 7, 6, 6},
+		{6, 7, 8, 7},
+		{8, 10, 8, 6},
+		{20, 20, 8, 4},
+		{6, 7, 0, DefaultMaxDepth},
+	} {
+		g := New(tc.rows, tc.cols, "normal")
+		g.MaxDepth = tc.maxDepth
+		if got := g.SearchDepth(AIHard); got != tc.want {
+			t.Errorf("plateau %dx%d, MaxDepth %d : profondeur %d, attendu %d", tc.rows, tc.cols, tc.maxDepth, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package engine contient les règles de Puissance 4 telles que les joue le serveur : plateau
+// avec obstacles, gravité variable selon le mode, parties de 2 à 4 joueurs, pendule, positions
+// de départ et IA. Il ne dépend ni de HTTP ni des templates et peut être intégré tel quel à un
+// autre programme (engine.NewPlayers pour une partie à plus de deux joueurs) :
+//
+//	g := engine.New(6, 7, "inverse")
+//	g.DropToken(3)                     // joue dans la colonne 4
+//	g.DropToken(g.AIMove(engine.AIHard))
+//	if g.GameOver { fmt.Println("vainqueur :", g.Winner) }
+//
+// Les coups sont des indices d'entrée à partir de 0 : une colonne en gravité verticale, une
+// ligne en gravité latérale (voir MoveCount et ValidMoves).
+package engine
+
+import "time"
+
+// Obstacle marque une case neutre : elle n'appartient à aucun joueur, ne reçoit jamais de
+// jeton, arrête le glissement des jetons en gravité latérale et ne compte dans aucun alignement.
+const Obstacle = -1
+
+// Game est l'état d'une partie. Les cases du plateau valent 0 (vide), le numéro du joueur
+// (1 à 4) ou Obstacle ; Board[0] est la rangée du haut.
+type Game struct {
+	Board         [][]int
+	Rows, Cols    int
+	CurrentPlayer int
+	Winner        int // Joueur gagnant (0 tant que personne n'a gagné, ou en cas de nul)
+	GameOver      bool
+	LastRow       int // Case du dernier coup joué (-1 avant le premier coup)
+	LastCol       int
+	TurnCount     int
+	Gravity       Gravity
+	Mode          string // "normal", "inverse", "lateral" ou "rotating"
+	Players       int    // Nombre de joueurs (2 à 4)
+	Eliminated    []bool // Joueurs éliminés (abandon) dans une partie à plusieurs
+	TimeControl   TimeControl
+	Clock         *Clock  // nil pour une partie sans pendule
+	FlagFall      int     // Joueur dont le temps s'est écoulé (0 si aucun)
+	Moves         []int   // Indices joués, dans l'ordre
+	Initial       [][]int // Plateau avant le premier coup, pour rejouer la partie
+	FirstPlayer   int     // Joueur qui a joué le premier coup
+	MaxDepth      int     // Profondeur de recherche de l'IA difficile (0 : DefaultMaxDepth)
+	// Éliminations (abandon ou temps écoulé) dans l'ordre, pour rejouer la partie
+	Eliminations []Elimination
+}
+
+// Elimination note qu'un joueur a quitté une partie à plusieurs après Move coups joués.
+type Elimination struct {
+	Player int
+	Move   int
+}
+
+// MaxPlayers est le nombre maximal de joueurs d'une partie.
+const MaxPlayers = 4
+
+// New crée une partie à deux joueurs sur un plateau vide de rows x cols. Le mode fixe le cycle
+// des gravités (voir GravitySchedule) ; un mode inconnu garde la gravité normale.
+func New(rows, cols int, mode string) *Game {
+	return NewPlayers(rows, cols, 2, mode)
+}
+
+// NewPlayers crée comme New une partie à players joueurs (ramené entre 2 et MaxPlayers) qui
+// jouent à tour de rôle, le joueur 1 en premier. À plus de deux, un joueur peut abandonner
+// (Resign) ; l'IA ne joue que les parties à deux.
+func NewPlayers(rows, cols, players int, mode string) *Game {
+	players = min(max(players, 2), MaxPlayers)
+	board := make([][]int, rows)
+	for r := range board {
+		board[r] = make([]int, cols)
+	}
+	gravity := GravityDown
+	if schedule := GravitySchedule(mode); len(schedule) > 0 {
+		gravity = schedule[0]
+	}
+	return &Game{
+		Board:         board,
+		Rows:          rows,
+		Cols:          cols,
+		CurrentPlayer: 1,
+		LastRow:       -1,
+		LastCol:       -1,
+		Gravity:       gravity,
+		Mode:          mode,
+		Players:       players,
+		Eliminated:    make([]bool, players),
+	}
+}
+
+// Clone retourne une copie indépendante de la partie : la recherche d'un coup sur la copie
+// peut se faire sans retenir le verrou qui protège l'original.
+func (g *Game) Clone() *Game {
+	c := *g
+	c.Board = CopyBoard(g.Board)
+	c.Eliminated = append([]bool(nil), g.Eliminated...)
+	c.Moves = append([]int(nil), g.Moves...)
+	c.Eliminations = append([]Elimination(nil), g.Eliminations...)
+	if g.Initial != nil {
+		c.Initial = CopyBoard(g.Initial)
+	}
+	if g.Clock != nil {
+		clock := *g.Clock
+		clock.Remaining = append([]time.Duration(nil), g.Clock.Remaining...)
+		c.Clock = &clock
+	}
+	return &c
+}
+
+// DropToken joue un jeton du joueur courant à l'indice index et retourne faux si le coup est
+// impossible (file pleine, partie terminée, drapeau tombé). index désigne la colonne d'entrée (gravité verticale) ou la ligne d'entrée (gravité latérale).
+func (g *Game) DropToken(index int) bool {
+	// Un joueur dont le temps est écoulé ne peut plus jouer
+	if g.CheckFlag() || g.GameOver {
+		return false
+	}
+	row, col := g.Landing(index)
+	if row < 0 {
+		return false
+	}
+	g.punchClock()
+	if len(g.Moves) == 0 {
+		g.Initial = CopyBoard(g.Board)
+		g.FirstPlayer = g.CurrentPlayer
+	}
+	g.Board[row][col] = g.CurrentPlayer
+	g.Moves = append(g.Moves, index)
+	g.LastRow = row
+	g.LastCol = col
+	g.TurnCount++
+	// Changement de gravité tous les GravityPeriod coups selon le mode (inverse, latéral, rotatif)
+	g.advanceGravity()
+	if g.CheckWin(row, col) {
+		g.Winner = g.CurrentPlayer
+		g.GameOver = true
+	} else if g.IsDraw() {
+		g.GameOver = true
+	}
+	g.CurrentPlayer = g.NextPlayer()
+	return true
+}
+
+// CheckWin vérifie si le dernier coup joué (row, col) crée un alignement de 4 jetons de même couleur.
+func (g *Game) CheckWin(row, col int) bool {
+	player := g.Board[row][col]
+	dirs := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+	for _, d := range dirs {
+		count := 1
+		for i := 1; i < 4; i++ {
+			r := row + d[0]*i
+			c := col + d[1]*i
+			if r >= 0 && r < g.Rows && c >= 0 && c < g.Cols && g.Board[r][c] == player {
+				count++
+			} else {
+				break
+			}
+		}
+		for i := 1; i < 4; i++ {
+			r := row - d[0]*i
+			c := col - d[1]*i
+			if r >= 0 && r < g.Rows && c >= 0 && c < g.Cols && g.Board[r][c] == player {
+				count++
+			} else {
+				break
+			}
+		}
+		if count >= 4 {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidMoves retourne les indices d'entrée (colonnes ou lignes selon la gravité) où il est possible de jouer
+func (g *Game) ValidMoves() []int {
+	var moves []int
+	for index := 0; index < g.MoveCount(); index++ {
+		// Vérifie si la file a encore une case libre
+		if row, _ := g.Landing(index); row >= 0 {
+			moves = append(moves, index)
+		}
+	}
+	return moves
+}
+
+// WinningPositions retourne les positions des 4 jetons gagnants si victoire, sinon nil.
+func (g *Game) WinningPositions() [][2]int {
+	player := g.Winner
+	if player == 0 {
+		return nil
+	}
+	dirs := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+	for r := 0; r < g.Rows; r++ {
+		for c := 0; c < g.Cols; c++ {
+			if g.Board[r][c] != player {
+				continue
+			}
+			for _, d := range dirs {
+				positions := [][2]int{{r, c}}
+				for i := 1; i < 4; i++ {
+					r2 := r + d[0]*i
+					c2 := c + d[1]*i
+					if r2 >= 0 && r2 < g.Rows && c2 >= 0 && c2 < g.Cols && g.Board[r2][c2] == player {
+						positions = append(positions, [2]int{r2, c2})
+					} else {
+						break
+					}
+				}
+				if len(positions) == 4 {
+					return positions
+				}
+			}
+		}
+	}
+	return nil
+}
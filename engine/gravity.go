@@ -0,0 +1,157 @@
+package engine
+
+// Gravity est le sens dans lequel glissent les jetons.
+type Gravity int
+
+const (
+	GravityDown Gravity = iota
+	GravityUp
+	GravityLeft  // les jetons entrent par la droite et glissent vers la gauche
+	GravityRight // les jetons entrent par la gauche et glissent vers la droite
+)
+
+// Nombre de coups entre deux changements de gravité dans les modes à gravité variable.
+const GravityPeriod = 5
+
+// Cycle des gravités de chaque mode de jeu : la partie commence avec la première
+// et passe à la suivante tous les GravityPeriod coups.
+var gravitySchedules = map[string][]Gravity{
+	"normal":   {GravityDown},
+	"inverse":  {GravityUp, GravityDown},
+	"lateral":  {GravityRight, GravityLeft},
+	"rotating": {GravityDown, GravityRight, GravityUp, GravityLeft},
+}
+
+// GravitySchedule retourne le cycle de gravités du mode, ou nil si le mode est inconnu.
+func GravitySchedule(mode string) []Gravity {
+	return gravitySchedules[mode]
+}
+
+// Delta retourne la direction dans laquelle glissent les jetons.
+func (gr Gravity) Delta() (int, int) {
+	switch gr {
+	case GravityUp:
+		return -1, 0
+	case GravityLeft:
+		return 0, -1
+	case GravityRight:
+		return 0, 1
+	default:
+		return 1, 0
+	}
+}
+
+// Horizontal indique si les jetons glissent le long d'une ligne.
+func (gr Gravity) Horizontal() bool {
+	return gr == GravityLeft || gr == GravityRight
+}
+
+// String retourne le nom de la gravité : down, up, left ou right.
+func (gr Gravity) String() string {
+	switch gr {
+	case GravityUp:
+		return "up"
+	case GravityLeft:
+		return "left"
+	case GravityRight:
+		return "right"
+	default:
+		return "down"
+	}
+}
+
+// Arrow retourne une flèche indiquant le sens de la gravité.
+func (gr Gravity) Arrow() string {
+	switch gr {
+	case GravityUp:
+		return "⬆️"
+	case GravityLeft:
+		return "⬅️"
+	case GravityRight:
+		return "➡️"
+	default:
+		return "⬇️"
+	}
+}
+
+// MoveCount retourne le nombre d'indices d'entrée : une colonne par coup en gravité verticale,
+// une ligne par coup en gravité latérale.
+func (g *Game) MoveCount() int {
+	if g.Gravity.Horizontal() {
+		return g.Rows
+	}
+	return g.Cols
+}
+
+// Landing retourne la case où s'arrête un jeton inséré à l'indice index, ou (-1, -1)
+// si la file est pleine.
+//
+// En gravité verticale, le jeton prend la case libre la plus éloignée de la colonne dans le
+// sens de la gravité : après un retournement en mode inverse, une colonne dont le bord
+// d'entrée est occupé reste jouable tant qu'elle a une case libre. En gravité latérale, le
+// jeton entre par le bord opposé à la gravité et glisse jusqu'au premier jeton ou obstacle
+// rencontré ; si la case d'entrée est occupée, il prend la case libre la plus éloignée de la
+// ligne, comme en gravité verticale. Une file n'est donc bloquée que lorsqu'elle est pleine :
+// aucune case libre ne devient inaccessible.
+func (g *Game) Landing(index int) (int, int) {
+	if index < 0 || index >= g.MoveCount() {
+		return -1, -1
+	}
+	if g.Gravity.Horizontal() {
+		row, col := index, 0
+		if g.Gravity == GravityLeft {
+			col = g.Cols - 1
+		}
+		if g.Board[row][col] == 0 {
+			dr, dc := g.Gravity.Delta()
+			for {
+				r, c := row+dr, col+dc
+				if r < 0 || r >= g.Rows || c < 0 || c >= g.Cols || g.Board[r][c] != 0 {
+					return row, col
+				}
+				row, col = r, c
+			}
+		}
+	}
+	return g.farthestEmpty(index)
+}
+
+// farthestEmpty retourne la case libre de la file index la plus éloignée dans le sens de la
+// gravité, ou (-1, -1) si la file est pleine.
+func (g *Game) farthestEmpty(index int) (int, int) {
+	// Départ du bord vers lequel glissent les jetons, en remontant la file
+	var row, col int
+	switch g.Gravity {
+	case GravityUp:
+		row, col = 0, index
+	case GravityLeft:
+		row, col = index, 0
+	case GravityRight:
+		row, col = index, g.Cols-1
+	default:
+		row, col = g.Rows-1, index
+	}
+	dr, dc := g.Gravity.Delta()
+	for ; row >= 0 && row < g.Rows && col >= 0 && col < g.Cols; row, col = row-dr, col-dc {
+		if g.Board[row][col] == 0 {
+			return row, col
+		}
+	}
+	return -1, -1
+}
+
+// advanceGravity passe à la gravité suivante du cycle du mode lorsque le nombre
+// de coups joués est un multiple de GravityPeriod.
+func (g *Game) advanceGravity() {
+	schedule := GravitySchedule(g.Mode)
+	if len(schedule) < 2 || g.TurnCount%GravityPeriod != 0 {
+		return
+	}
+	for i, gr := range schedule {
+		if gr == g.Gravity {
+			g.Gravity = schedule[(i+1)%len(schedule)]
+			return
+		}
+	}
+	g.Gravity = schedule[0]
+}
@@ -0,0 +1,59 @@
+package engine
+
+import "testing"
+
+func TestInverseColumnWithTopTokenPlayableAfterFlip(t *testing.T) {
+	g := New(6, 7, "inverse")
+	// Colonnes 1, 2, 3, 4 et 6 : les cinq premiers jetons montent vers le bord du haut
+	for _, index := range []int{0, 1, 2, 3, 5} {
+		if !g.DropToken(index) {
+			t.Fatalf("coup %d refusé", index+1)
+		}
+	}
+	if g.Gravity != GravityDown {
+		t.Fatalf("gravité %v après 5 coups, attendu down", g.Gravity)
+	}
+	if got := len(g.ValidMoves()); got != g.Cols {
+		t.Fatalf("ValidMoves = %d coups après le retournement, attendu %d", got, g.Cols)
+	}
+	if !g.DropToken(0) {
+		t.Fatal("colonne 1 injouable alors que son jeton du haut laisse 5 cases libres")
+	}
+	if g.LastRow != g.Rows-1 || g.LastCol != 0 {
+		t.Fatalf("jeton en (%d, %d), attendu en bas de la colonne 1", g.LastRow, g.LastCol)
+	}
+}
+
+func TestVerticalGravityFillsFarthestEmptyCell(t *testing.T) {
+	g := New(6, 7, "normal")
+	// Obstacle suspendu : la colonne se remplit depuis le bas, sous l'obstacle
+	g.Board[2][3] = Obstacle
+	for want := g.Rows - 1; want >= 0; want-- {
+		if want == 2 {
+			continue
+		}
+		if !g.DropToken(3) {
+			t.Fatalf("colonne 4 bloquée avec la rangée %d libre", want+1)
+		}
+		if g.LastRow != want {
+			t.Fatalf("jeton en rangée %d, attendu %d", g.LastRow+1, want+1)
+		}
+	}
+	if g.DropToken(3) {
+		t.Fatal("coup accepté dans une colonne pleine")
+	}
+}
+
+func TestLateralGravitySlidesFromEntry(t *testing.T) {
+	g := New(6, 7, "lateral")
+	// Gravité vers la droite : le jeton glisse jusqu'à l'obstacle
+	g.Board[2][4] = Obstacle
+	if row, col := g.Landing(2); row != 2 || col != 3 {
+		t.Fatalf("Landing(2) = (%d, %d), attendu (2, 3)", row, col)
+	}
+	// Case d'entrée occupée : le jeton prend la case libre la plus à droite
+	g.Board[2][0] = 1
+	if row, col := g.Landing(2); row != 2 || col != 6 {
+		t.Fatalf("Landing(2) = (%d, %d), attendu (2, 6)", row, col)
+	}
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+)
+
+// Layout est une position de départ : obstacles et jetons déjà posés.
+//
+// Format texte (une ligne par rangée, de haut en bas) :
+//
+//	// commentaire
+//	.......   case vide
+//	..#....   obstacle neutre
+//	..12...   jeton du joueur 1 à 4
+type Layout struct {
+	Name       string
+	Rows, Cols int
+	Cells      [][]int
+}
+
+// ParseLayout lit un plateau au format texte.
+func ParseLayout(name string, r io.Reader) (*Layout, error) {
+	var cells [][]int
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		row := make([]int, 0, len(line))
+		for _, ch := range line {
+			switch ch {
+			case '.':
+				row = append(row, 0)
+			case '#':
+				row = append(row, Obstacle)
+			case '1', '2', '3', '4':
+				row = append(row, int(ch-'0'))
+			default:
+				return nil, fmt.Errorf("ligne %d : caractère %q inconnu", len(cells)+1, ch)
+			}
+		}
+		if len(cells) > 0 && len(row) != len(cells[0]) {
+			return nil, fmt.Errorf("ligne %d : %d colonnes au lieu de %d", len(cells)+1, len(row), len(cells[0]))
+		}
+		cells = append(cells, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cells) < 4 || len(cells[0]) < 4 {
+		return nil, errors.New("le plateau doit faire au moins 4x4")
+	}
+	return &Layout{Name: name, Rows: len(cells), Cols: len(cells[0]), Cells: cells}, nil
+}
+
+// ApplyLayout remplace le plateau de la partie par la position de départ l,
+// après avoir vérifié qu'elle est jouable avec la gravité initiale.
+func (g *Game) ApplyLayout(l *Layout) error {
+	board := CopyBoard(l.Cells)
+	if err := ValidateLayout(board, g.Gravity, g.Players); err != nil {
+		return err
+	}
+	g.Board = board
+	g.Rows, g.Cols = l.Rows, l.Cols
+	g.CurrentPlayer = StartingPlayer(board, g.Players)
+	return nil
+}
+
+// CopyBoard retourne une copie indépendante d'un plateau.
+func CopyBoard(board [][]int) [][]int {
+	copied := make([][]int, len(board))
+	for r := range board {
+		copied[r] = append([]int(nil), board[r]...)
+	}
+	return copied
+}
+
+// ValidateLayout garantit qu'une position de départ est cohérente :
+// chaque jeton repose sur un bord, un jeton ou un obstacle dans le sens de la gravité,
+// aucun joueur n'a déjà aligné 4 jetons, aucun joueur n'a plus d'un jeton d'avance
+// et il reste au moins un coup jouable.
+func ValidateLayout(board [][]int, gravity Gravity, players int) error {
+	g := &Game{Board: board, Rows: len(board), Cols: len(board[0]), Gravity: gravity}
+	counts := map[int]int{}
+	for r := 0; r < g.Rows; r++ {
+		for c := 0; c < g.Cols; c++ {
+			player := board[r][c]
+			if player <= 0 {
+				continue
+			}
+			if player > players {
+				return fmt.Errorf("jeton du joueur %d dans une partie à %d joueurs", player, players)
+			}
+			counts[player]++
+			dr, dc := gravity.Delta()
+			below, side := r+dr, c+dc
+			if below >= 0 && below < g.Rows && side >= 0 && side < g.Cols && board[below][side] == 0 {
+				return fmt.Errorf("le jeton en (%d, %d) flotte au-dessus d'une case vide", r+1, c+1)
+			}
+			if g.CheckWin(r, c) {
+				return fmt.Errorf("le joueur %d a déjà un alignement de 4", player)
+			}
+		}
+	}
+	for p := 1; p <= players; p++ {
+		for q := 1; q <= players; q++ {
+			if counts[p]-counts[q] > 1 {
+				return errors.New("un joueur a plus d'un jeton d'avance")
+			}
+		}
+	}
+	if len(g.ValidMoves()) == 0 {
+		return errors.New("aucun coup n'est jouable")
+	}
+	return nil
+}
+
+// StartingPlayer désigne qui joue en premier sur une position : celui qui a le moins de jetons,
+// le plus petit numéro en cas d'égalité.
+func StartingPlayer(board [][]int, players int) int {
+	counts := map[int]int{}
+	for _, row := range board {
+		for _, v := range row {
+			counts[v]++
+		}
+	}
+	first := 1
+	for p := 2; p <= players; p++ {
+		if counts[p] < counts[first] {
+			first = p
+		}
+	}
+	return first
+}
+
+// RandomLayout génère un plateau pré-rempli : des obstacles posés au hasard puis exactement
+// tokens jetons joués comme de vrais coups (en alternance, en respectant la gravité) sans
+// jamais créer d'alignement. Un essai où un jeton ne trouve plus de place est recommencé en
+// entier plutôt que gardé à moitié rempli : le nombre de jetons de chacun ne dépend que de
+// tokens. Le résultat passe toujours ValidateLayout.
+func RandomLayout(rows, cols, tokens, obstacles, players int, gravity Gravity, rng *rand.Rand) [][]int {
+attempts:
+	for attempt := 0; ; attempt++ {
+		board := make([][]int, rows)
+		for i := range board {
+			board[i] = make([]int, cols)
+		}
+		// Au-delà de quelques essais ratés, on abandonne le pré-remplissage plutôt que de boucler
+		if attempt >= 50 {
+			return board
+		}
+		for n := 0; n < obstacles; {
+			r := rng.Intn(rows)
+			c := rng.Intn(cols)
+			if board[r][c] == 0 {
+				board[r][c] = Obstacle
+				n++
+			}
+		}
+		g := &Game{Board: board, Rows: rows, Cols: cols, Gravity: gravity}
+		player := 1
+		for n := 0; n < tokens; n++ {
+			moves := g.ValidMoves()
+			rng.Shuffle(len(moves), func(i, j int) { moves[i], moves[j] = moves[j], moves[i] })
+			placed := false
+			for _, index := range moves {
+				row, col, prev := g.simulateMove(index, player)
+				if !g.CheckWin(row, col) {
+					placed = true
+					break
+				}
+				g.undoMove(row, col, prev)
+			}
+			if !placed {
+				continue attempts
+			}
+			player = player%players + 1
+		}
+		if ValidateLayout(board, gravity, players) == nil {
+			return board
+		}
+	}
+}
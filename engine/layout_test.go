@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRandomLayoutPlacesEveryToken(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, tc := range []struct{ rows, cols, tokens, obstacles, players int }{
+		{8, 10, 6, 3, 2},
+		{5, 5, 16, 0, 2},
+		{6, 7, 30, 0, 2},
+		{7, 9, 9, 2, 3},
+	} {
+		for _, gravity := range []Gravity{GravityDown, GravityUp, GravityLeft, GravityRight} {
+			for i := 0; i < 200; i++ {
+				board := RandomLayout(tc.rows, tc.cols, tc.tokens, tc.obstacles, tc.players, gravity, rng)
+				if err := ValidateLayout(board, gravity, tc.players); err != nil {
+					t.Fatalf("%+v, gravité %v : %v", tc, gravity, err)
+				}
+				counts := map[int]int{}
+				for _, row := range board {
+					for _, v := range row {
+						counts[v]++
+					}
+				}
+				// Plateau complet, ou vide après trop d'essais ratés : jamais à moitié rempli
+				if len(counts) == 1 {
+					continue
+				}
+				for p := 1; p <= tc.players; p++ {
+					want := tc.tokens / tc.players
+					if p <= tc.tokens%tc.players {
+						want++
+					}
+					if counts[p] != want {
+						t.Fatalf("%+v, gravité %v : %d jetons pour le joueur %d, attendu %d\n%v", tc, gravity, counts[p], p, want, board)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package engine
+
+// Parties à plusieurs : ordre de jeu et abandons.
+
+// NextPlayer retourne le joueur suivant dans la rotation en sautant les joueurs éliminés.
+func (g *Game) NextPlayer() int {
+	if g.Players <= 2 {
+		return 3 - g.CurrentPlayer
+	}
+	p := g.CurrentPlayer
+	for i := 0; i < g.Players; i++ {
+		p = p%g.Players + 1
+		if !g.Eliminated[p-1] {
+			return p
+		}
+	}
+	return g.CurrentPlayer
+}
+
+// ActivePlayers liste les joueurs encore en lice.
+func (g *Game) ActivePlayers() []int {
+	var active []int
+	for p := 1; p <= g.Players; p++ {
+		if !g.Eliminated[p-1] {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+// Eliminate retire un joueur de la partie : ses jetons restent sur le plateau mais il ne joue plus.
+// S'il ne reste qu'un joueur, celui-ci gagne.
+func (g *Game) Eliminate(player int) {
+	if g.GameOver || player < 1 || player > g.Players || g.Eliminated[player-1] {
+		return
+	}
+	g.Eliminated[player-1] = true
+	g.Eliminations = append(g.Eliminations, Elimination{Player: player, Move: len(g.Moves)})
+	if active := g.ActivePlayers(); len(active) == 1 {
+		g.Winner = active[0]
+		g.GameOver = true
+		return
+	}
+	if g.CurrentPlayer == player {
+		g.CurrentPlayer = g.NextPlayer()
+		if g.Clock != nil {
+			g.Clock.TurnStart = timeNow()
+		}
+	}
+}
+
+// Resign fait abandonner le joueur courant dans une partie à plusieurs.
+func (g *Game) Resign() bool {
+	if g.GameOver || g.Players <= 2 {
+		return false
+	}
+	g.Eliminate(g.CurrentPlayer)
+	return true
+}
@@ -0,0 +1,41 @@
+package engine
+
+import "testing"
+
+func TestNewPlayersRotatesAndResigns(t *testing.T) {
+	g := NewPlayers(7, 9, 3, "normal")
+	if g.Players != 3 || len(g.Eliminated) != 3 || g.CurrentPlayer != 1 {
+		t.Fatalf("partie à 3 mal initialisée : %d joueurs, %d éliminations, joueur %d", g.Players, len(g.Eliminated), g.CurrentPlayer)
+	}
+	for i, want := range []int{2, 3, 1} {
+		if !g.DropToken(i) {
+			t.Fatalf("coup %d refusé", i+1)
+		}
+		if g.CurrentPlayer != want {
+			t.Fatalf("après le coup %d : joueur %d, attendu %d", i+1, g.CurrentPlayer, want)
+		}
+	}
+	g.DropToken(3)
+	if !g.Resign() || g.CurrentPlayer != 3 || !g.Eliminated[1] {
+		t.Fatalf("abandon du joueur 2 : joueur courant %d, éliminés %v", g.CurrentPlayer, g.Eliminated)
+	}
+	g.DropToken(4)
+	if g.CurrentPlayer != 1 {
+		t.Errorf("le joueur éliminé n'est pas sauté : joueur %d", g.CurrentPlayer)
+	}
+	g.Resign()
+	if !g.GameOver || g.Winner != 3 {
+		t.Errorf("dernier joueur en lice non vainqueur : fin %v, vainqueur %d", g.GameOver, g.Winner)
+	}
+}
+
+func TestNewPlayersClampsCount(t *testing.T) {
+	for _, tc := range []struct{ players, want int }{{0, 2}, {2, 2}, {4, 4}, {9, MaxPlayers}} {
+		if g := NewPlayers(6, 7, tc.players, "normal"); g.Players != tc.want || len(g.Eliminated) != tc.want {
+			t.Errorf("NewPlayers(%d) : %d joueurs, attendu %d", tc.players, g.Players, tc.want)
+		}
+	}
+	if g := New(6, 7, "normal"); g.Players != 2 || g.Resign() {
+		t.Error("New doit créer une partie à deux joueurs, sans abandon")
+	}
+}
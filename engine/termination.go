@@ -0,0 +1,15 @@
+package engine
+
+// Fin de partie sans vainqueur.
+//
+// Une file (colonne ou ligne selon la gravité) reste jouable tant qu'elle a une case libre,
+// quel que soit l'état de sa case d'entrée (voir Landing). Toute case libre appartient à une
+// file de chaque gravité : la partie n'est nulle que lorsque plus aucune case n'est libre,
+// et une partie en cours laisse toujours un coup au joueur, quelle que soit la gravité du
+// cycle du mode.
+
+// IsDraw vérifie qu'aucun coup n'est jouable : toutes les cases sont occupées par un jeton
+// ou un obstacle.
+func (g *Game) IsDraw() bool {
+	return len(g.ValidMoves()) == 0
+}
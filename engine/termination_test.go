@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+var allModes = []string{"normal", "inverse", "lateral", "rotating"}
+
+// emptyCells est l'oracle indépendant des règles de pose : il compte les cases libres du
+// plateau. Toute case libre doit rester jouable, donc un nul n'en laisse aucune.
+func emptyCells(board [][]int) int {
+	n := 0
+	for _, row := range board {
+		for _, v := range row {
+			if v == 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// randomBoard remplit un plateau au hasard : cases vides, jetons et obstacles.
+// fill règle la proportion de cases occupées pour couvrir aussi les plateaux presque pleins.
+func randomBoard(rng *rand.Rand, rows, cols int, fill float64, obstacles bool) [][]int {
+	board := make([][]int, rows)
+	for r := range board {
+		board[r] = make([]int, cols)
+		for c := range board[r] {
+			if rng.Float64() >= fill {
+				continue
+			}
+			board[r][c] = rng.Intn(2) + 1
+			if obstacles && rng.Intn(6) == 0 {
+				board[r][c] = Obstacle
+			}
+		}
+	}
+	return board
+}
+
+func TestIsDrawMatchesFullBoard(t *testing.T) {
+	for _, mode := range allModes {
+		for _, obstacles := range []bool{false, true} {
+			property := func(seed int64) bool {
+				rng := rand.New(rand.NewSource(seed))
+				rows, cols := 4+rng.Intn(5), 4+rng.Intn(7)
+				fill := []float64{0.3, 0.9, 0.99, 1}[rng.Intn(4)]
+				schedule := GravitySchedule(mode)
+				g := &Game{
+					Board:   randomBoard(rng, rows, cols, fill, obstacles),
+					Rows:    rows,
+					Cols:    cols,
+					Mode:    mode,
+					Gravity: schedule[rng.Intn(len(schedule))],
+				}
+				return g.IsDraw() == (emptyCells(g.Board) == 0)
+			}
+			if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+				t.Errorf("mode %s, obstacles %v : %v", mode, obstacles, err)
+			}
+		}
+	}
+}
+
+func TestRandomGamesTerminateCorrectly(t *testing.T) {
+	for _, mode := range allModes {
+		for _, obstacles := range []int{0, 4} {
+			property := func(seed int64) bool {
+				rng := rand.New(rand.NewSource(seed))
+				g := New(6, 7, mode)
+				g.Board = RandomLayout(6, 7, 0, obstacles, 2, g.Gravity, rng)
+				for !g.GameOver {
+					empty := emptyCells(g.Board)
+					// Une partie en cours doit toujours laisser un coup au joueur
+					moves := g.ValidMoves()
+					if len(moves) == 0 {
+						t.Logf("seed %d : aucun coup jouable avec %d cases libres", seed, empty)
+						return false
+					}
+					if !g.DropToken(moves[rng.Intn(len(moves))]) {
+						return false
+					}
+					// Le jeton occupe une seule case, jusque-là libre
+					if emptyCells(g.Board) != empty-1 || g.Board[g.LastRow][g.LastCol] == 0 {
+						t.Logf("seed %d : coup posé hors d'une case libre", seed)
+						return false
+					}
+				}
+				if g.Winner != 0 {
+					return g.CheckWin(g.LastRow, g.LastCol)
+				}
+				// Nul : aucune case ne doit rester jouable
+				if n := emptyCells(g.Board); n != 0 {
+					t.Logf("seed %d : nul déclaré avec %d cases libres", seed, n)
+					return false
+				}
+				return true
+			}
+			if err := quick.Check(property, &quick.Config{MaxCount: 300}); err != nil {
+				t.Errorf("mode %s, obstacles %d : %v", mode, obstacles, err)
+			}
+		}
+	}
+}
+
+func TestInverseTopRowFullIsNotDraw(t *testing.T) {
+	g := New(6, 7, "inverse")
+	// Avec la gravité vers le haut, la ligne du haut se remplit en premier
+	for c := 0; c < g.Cols; c++ {
+		g.Board[0][c] = c%2 + 1
+	}
+	if g.IsDraw() {
+		t.Fatal("partie déclarée nulle alors que 35 cases restent libres")
+	}
+	if got := len(g.ValidMoves()); got != g.Cols {
+		t.Fatalf("ValidMoves = %d coups, attendu %d", got, g.Cols)
+	}
+}
+
+func TestBlockedEntriesKeepRowsPlayable(t *testing.T) {
+	g := New(6, 7, "lateral")
+	// Gravité vers la droite : toutes les entrées de gauche sont bouchées par des obstacles
+	for r := 0; r < g.Rows; r++ {
+		g.Board[r][0] = Obstacle
+	}
+	if g.IsDraw() {
+		t.Fatal("partie déclarée nulle alors que 36 cases restent libres")
+	}
+	if got := len(g.ValidMoves()); got != g.Rows {
+		t.Fatalf("ValidMoves = %d coups, attendu %d", got, g.Rows)
+	}
+}
@@ -0,0 +1,104 @@
+// Page de jeu : interactions du plateau, coup de l'IA, pendules et suivi en direct (la page
+// de bilan n'en utilise que la sélection du lien de replay).
+// Les réglages arrivent par attributs data-* (aucun script en ligne, pour la Content-Security-Policy).
+(function () {
+    // Clic directement sur une colonne du plateau et surbrillance au survol
+    function setupBoard() {
+        const board = document.getElementById('board');
+        if (!board || board.dataset.play !== '1') return;
+        const form = document.getElementById('board-form');
+        const colInput = document.getElementById('col-input');
+        // En gravité latérale on choisit une ligne, sinon une colonne
+        const axis = board.dataset.axis || 'col';
+        function setColHighlight(index, on) {
+            board.querySelectorAll('td[data-' + axis + '="' + index + '"]').forEach(function (td) {
+                td.classList.toggle('col-selected', on);
+            });
+        }
+        board.querySelectorAll('td').forEach(function (td) {
+            const index = td.getAttribute('data-' + axis);
+            if (index === null) return;
+            td.addEventListener('mouseenter', function () { setColHighlight(index, true); });
+            td.addEventListener('mouseleave', function () { setColHighlight(index, false); });
+            td.addEventListener('click', function () {
+                colInput.value = index;
+                form.submit();
+            });
+        });
+    }
+
+    // Au tour de l'IA en mode Humain vs IA, on lance un fetch vers /ai-move après un délai
+    function setupAI() {
+        const board = document.getElementById('board');
+        if (!board || board.dataset.aiDelay === undefined) return;
+        const csrf = document.querySelector('#board-form input[name="csrf"]').value;
+        setTimeout(function () {
+            fetch('/ai-move?game=' + encodeURIComponent(board.dataset.game), {
+                method: 'POST',
+                headers: { 'X-CSRF-Token': csrf }
+            }).then(function () { window.location.reload(); });
+        }, parseInt(board.dataset.aiDelay, 10));
+    }
+
+    // Partie entre humains : la page se recharge dès qu'un autre joueur (autre navigateur,
+    // client terminal) joue ou lance la revanche
+    function setupLive() {
+        const body = document.body;
+        if (body.dataset.version === undefined || !window.EventSource) return;
+        const version = parseInt(body.dataset.version, 10);
+        const events = new EventSource('/api/events?game=' + encodeURIComponent(body.dataset.game));
+        events.onmessage = function (e) {
+            if (JSON.parse(e.data).version !== version) {
+                events.close();
+                window.location.reload();
+            }
+        };
+    }
+
+    // Affichage des pendules : le serveur fait foi, le navigateur ne fait que décompter
+    // et recharge la page quand un temps atteint zéro pour que le serveur constate la chute.
+    function setupClocks() {
+        const clocks = Array.from(document.querySelectorAll('#clocks .clock'));
+        if (clocks.length === 0) return;
+        const startedAt = Date.now();
+        function format(ms) {
+            const total = Math.max(0, Math.ceil(ms / 1000));
+            const m = Math.floor(total / 60);
+            const s = total % 60;
+            return m + ':' + (s < 10 ? '0' : '') + s;
+        }
+        let reloading = false;
+        function tick() {
+            clocks.forEach(function (el) {
+                let ms = parseInt(el.dataset.remaining, 10);
+                if (el.dataset.running === '1') {
+                    ms -= Date.now() - startedAt;
+                    if (ms <= 0 && !reloading) {
+                        reloading = true;
+                        window.location.reload();
+                    }
+                }
+                el.querySelector('.clock-time').textContent = format(ms);
+                el.classList.toggle('low', ms < 10000);
+            });
+        }
+        tick();
+        setInterval(tick, 200);
+    }
+
+    document.addEventListener('DOMContentLoaded', function () {
+        setupBoard();
+        setupAI();
+        setupLive();
+        setupClocks();
+        // Les liens d'invitation et de replay se sélectionnent d'un clic
+        document.querySelectorAll('.share input').forEach(function (input) {
+            input.addEventListener('click', function () { input.select(); });
+        });
+        const boardArea = document.getElementById('gameBoardArea');
+        if (boardArea) {
+            // Centre le plateau verticalement sur l'écran
+            boardArea.scrollIntoView({ behavior: "smooth", block: "center" });
+        }
+    });
+})();
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+
+	"power4/engine"
+)
+
+// Export d'une partie en GIF animé : une image par coup, un changement de gravité signalé par
+// une flèche, puis l'alignement gagnant qui clignote. Les abandons des parties à plusieurs
+// sont rejoués à leur place entre les coups, pour que les joueurs suivants gardent leur couleur.
+
+// Durées d'affichage, en centièmes de seconde.
+const (
+	gifStartDelay   = 80
+	gifMoveDelay    = 50
+	gifGravityDelay = 70
+	gifFlashDelay   = 35
+	gifFlashes      = 4
+	gifEndDelay     = 300
+)
+
+// replayStart retourne une partie vierge placée dans la position de départ de g, prête à rejouer ses coups.
+func (g *Game) replayStart() *Game {
+	initial, first := g.Initial, g.FirstPlayer
+	if len(g.Moves) == 0 || initial == nil {
+		initial, first = g.Board, g.CurrentPlayer
+	}
+	return newReplay(initial, first, g.Players, g.Colors, g.Mode)
+}
+
+// newReplay prépare une partie sans pendule à partir d'une position de départ.
+func newReplay(initial [][]int, first, players int, colors []string, mode string) *Game {
+	if engine.GravitySchedule(mode) == nil {
+		mode = "normal"
+	}
+	players = max(2, players)
+	g := NewGame(len(initial), len(initial[0]), 0, 0, "", "", "", mode, "", ModeHumanVsHuman, engine.AIEasy)
+	g.Board = engine.CopyBoard(initial)
+	g.Players = players
+	g.Usernames = make([]string, players)
+	g.Colors = parseColors(colors, players)
+	g.Eliminated = make([]bool, players)
+	g.Accounts = make([]string, players)
+	if first >= 1 && first <= players {
+		g.CurrentPlayer = first
+	}
+	return g
+}
+
+// drawGravity trace une barre le long du bord vers lequel tombent les jetons.
+func drawGravity(img *image.RGBA, gravity engine.Gravity, c color.RGBA) {
+	b := img.Rect
+	const t = 4
+	var bar image.Rectangle
+	switch gravity {
+	case engine.GravityUp:
+		bar = image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Min.Y+t)
+	case engine.GravityLeft:
+		bar = image.Rect(b.Min.X, b.Min.Y, b.Min.X+t, b.Max.Y)
+	case engine.GravityRight:
+		bar = image.Rect(b.Max.X-t, b.Min.Y, b.Max.X, b.Max.Y)
+	default:
+		bar = image.Rect(b.Min.X, b.Max.Y-t, b.Max.X, b.Max.Y)
+	}
+	fillRect(img, bar, c)
+}
+
+// drawArrow superpose au centre de l'image une grande flèche semi-transparente dans le sens de la gravité.
+func drawArrow(img *image.RGBA, gravity engine.Gravity, c color.RGBA) {
+	dr, dc := gravity.Delta()
+	cx, cy := float64(img.Rect.Dx())/2, float64(img.Rect.Dy())/2
+	size := float64(min(img.Rect.Dx(), img.Rect.Dy())) / 3
+	// Flèche = hampe + pointe, décrites dans le repère de la gravité (u vers l'avant, v sur le côté)
+	inside := func(u, v float64) bool {
+		switch {
+		case u >= -size && u < size/5:
+			return v >= -size/6 && v <= size/6
+		case u >= size/5 && u <= size:
+			half := (size - u) * 0.75
+			return v >= -half && v <= half
+		}
+		return false
+	}
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			px, py := float64(x)+0.5-cx, float64(y)+0.5-cy
+			u := px*float64(dc) + py*float64(dr)
+			v := px*float64(dr) - py*float64(dc)
+			if inside(u, v) {
+				blend(img, x, y, c, 0.6)
+			}
+		}
+	}
+}
+
+// gifFrame est une image de l'animation avant conversion en couleurs indexées.
+type gifFrame struct {
+	img   *image.RGBA
+	delay int
+}
+
+// framePalette choisit les 256 couleurs les plus fréquentes des images données ; les autres
+// (bords lissés) sont ramenées à la plus proche.
+func framePalette(frames ...*image.RGBA) color.Palette {
+	counts := map[color.RGBA]int{}
+	for _, img := range frames {
+		for i := 0; i+3 < len(img.Pix); i += 4 {
+			counts[color.RGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], 255}]++
+		}
+	}
+	colors := make([]color.RGBA, 0, len(counts))
+	for c := range counts {
+		colors = append(colors, c)
+	}
+	sort.Slice(colors, func(i, j int) bool {
+		if counts[colors[i]] != counts[colors[j]] {
+			return counts[colors[i]] > counts[colors[j]]
+		}
+		a, b := colors[i], colors[j]
+		return uint32(a.R)<<16|uint32(a.G)<<8|uint32(a.B) < uint32(b.R)<<16|uint32(b.G)<<8|uint32(b.B)
+	})
+	palette := make(color.Palette, 0, 256)
+	for _, c := range colors {
+		if len(palette) == 256 {
+			break
+		}
+		palette = append(palette, c)
+	}
+	return palette
+}
+
+// renderGIF rejoue les coups et les éliminations depuis la position de départ et retourne
+// l'animation.
+func renderGIF(start *Game, moves []int, eliminations []engine.Elimination, skin string) (*gif.GIF, error) {
+	palette := paletteFor(skin)
+	accent := hexColor(palette.Highlight)
+	g := start
+	paint := func(highlight bool) *image.RGBA {
+		img := paintFrame(g, palette, highlight)
+		if g.Mode != "normal" {
+			drawGravity(img, g.Gravity, accent)
+		}
+		return img
+	}
+	// eliminate applique les éliminations survenues avant le coup numéro i (à partir de 0)
+	eliminate := func(i int) {
+		for len(eliminations) > 0 && eliminations[0].Move <= i {
+			g.Eliminate(eliminations[0].Player)
+			eliminations = eliminations[1:]
+		}
+	}
+	frames := []gifFrame{{paint(false), gifStartDelay}}
+	for i, m := range moves {
+		eliminate(i)
+		before := g.Gravity
+		if !g.DropToken(m) {
+			return nil, fmt.Errorf("coup %d (%d) impossible", i+1, m+1)
+		}
+		frames = append(frames, gifFrame{paint(false), gifMoveDelay})
+		if g.Gravity != before && !g.GameOver {
+			flip := paint(false)
+			drawArrow(flip, g.Gravity, accent)
+			frames = append(frames, gifFrame{flip, gifGravityDelay})
+		}
+	}
+	eliminate(len(moves))
+	if g.GameOver && g.Winner != 0 {
+		lit := paint(true)
+		for i := 0; i < gifFlashes; i++ {
+			frames = append(frames, gifFrame{lit, gifFlashDelay}, gifFrame{paint(false), gifFlashDelay})
+		}
+		frames = append(frames, gifFrame{lit, gifEndDelay})
+	} else {
+		frames[len(frames)-1].delay = gifEndDelay
+	}
+
+	// Palette commune : la première et la dernière image contiennent toutes les couleurs utiles
+	colors := framePalette(frames[0].img, frames[len(frames)-1].img)
+	anim := &gif.GIF{}
+	for _, f := range frames {
+		indexed := image.NewPaletted(f.img.Rect, colors)
+		draw.Draw(indexed, indexed.Rect, f.img, image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, indexed)
+		anim.Delay = append(anim.Delay, f.delay)
+	}
+	return anim, nil
+}
+
+// recordReplay prépare le replay d'une partie terminée enregistrée dans la base : position de
+// départ, coups et éliminations. Retourne nil pour une partie inconnue ou enregistrée sans
+// position de départ.
+func recordReplay(s *Store, id string) (*Game, []int, []engine.Elimination) {
+	var rec *GameRecord
+	s.View(func(d *storeData) {
+		for _, r := range d.Results {
+			if r.ID == id {
+				rec = r
+			}
+		}
+	})
+	if rec == nil || rec.Initial == nil {
+		return nil, nil, nil
+	}
+	return newReplay(rec.Initial, rec.FirstPlayer, len(rec.Players), rec.Colors, rec.Mode), rec.Moves, rec.Eliminations
+}
+
+// gameGIFHandler sert /game.gif : l'animation d'une partie (?game=ID, en cours ou terminée, y
+// compris avant un redémarrage du serveur) ou d'une suite de coups en notation (mêmes
+// paramètres que /board.svg).
+func gameGIFHandler(w http.ResponseWriter, r *http.Request) {
+	if !acquireRender(w, r) {
+		return
+	}
+	defer releaseRender()
+	q := r.URL.Query()
+	var start *Game
+	var moves []int
+	var eliminations []engine.Elimination
+	skin := q.Get("skin")
+	name := "position"
+	if id := q.Get("game"); id != "" {
+		mutex.Lock()
+		g := games[id]
+		if g != nil {
+			start, moves = g.replayStart(), append([]int(nil), g.Moves...)
+			eliminations = append([]engine.Elimination(nil), g.Eliminations...)
+			if skin == "" {
+				skin = g.Skin
+			}
+		}
+		mutex.Unlock()
+		if start == nil {
+			// Partie terminée avant un redémarrage : rejouée depuis la base, lien de replay durable
+			start, moves, eliminations = recordReplay(store, id)
+		}
+		if start == nil {
+			http.Error(w, "Partie introuvable", http.StatusNotFound)
+			return
+		}
+		name = id
+	} else {
+		var err error
+		if moves, err = parseMoves(q.Get("moves")); err == nil {
+			// Position de départ seule, les coups sont rejoués par renderGIF
+			q.Del("moves")
+			start, err = positionFromQuery(q)
+		}
+		if err != nil {
+			http.Error(w, "Position invalide : "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	anim, err := renderGIF(start, moves, eliminations, skin)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var b bytes.Buffer
+	if err := gif.EncodeAll(&b, anim); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Content-Disposition", `inline; filename="power4-`+name+`.gif"`)
+	w.Write(b.Bytes())
+}
+
+// gifCommand exporte une partie en GIF sans lancer le serveur : une partie terminée de la base
+// (-game) ou une suite de coups en notation (-moves).
+func gifCommand(args []string) error {
+	fs := flag.NewFlagSet("gif", flag.ContinueOnError)
+	id := fs.String("game", "", "identifiant d'une partie terminée enregistrée dans la base")
+	notation := fs.String("moves", "", "coups en notation (ex. 4453)")
+	difficulty := fs.String("difficulty", "easy", "taille du plateau pour -moves (easy, normal, hard)")
+	mode := fs.String("mode", "normal", "gravité pour -moves (normal, inverse, lateral, rotating)")
+	layout := fs.String("layout", "", "plateau de départ pour -moves")
+	skin := fs.String("skin", "classic", "couleurs (classic, neon, retro)")
+	out := fs.String("o", "partie.gif", "fichier à écrire")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var start *Game
+	var moves []int
+	var eliminations []engine.Elimination
+	switch {
+	case *id != "":
+		s, err := OpenStore(dataPath())
+		if err != nil {
+			return err
+		}
+		if start, moves, eliminations = recordReplay(s, *id); start == nil {
+			return errors.New("partie " + *id + " introuvable parmi les parties terminées")
+		}
+	case *notation != "":
+		var err error
+		if moves, err = parseMoves(*notation); err != nil {
+			return err
+		}
+		q := url.Values{"difficulty": {*difficulty}, "mode": {*mode}, "layout": {*layout}}
+		if start, err = positionFromQuery(q); err != nil {
+			return err
+		}
+	default:
+		fs.Usage()
+		return errors.New("indiquer -game ou -moves")
+	}
+
+	anim, err := renderGIF(start, moves, eliminations, *skin)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGIFReplaysResignations(t *testing.T) {
+	g := NewMultiGame(6, 7, 0, 0, "easy", []string{"a", "b", "c"}, parseColors(nil, 3), "normal", "classic")
+	play := func(cols ...int) {
+		for _, c := range cols {
+			if !g.DropToken(c) {
+				t.Fatalf("coup %d refusé", c+1)
+			}
+		}
+	}
+	play(0, 1)
+	if !g.Resign() {
+		t.Fatal("abandon du joueur 3 refusé")
+	}
+	play(0, 1, 0, 1, 0)
+	if !g.GameOver || g.Winner != 1 {
+		t.Fatalf("partie non gagnée par le joueur 1 (vainqueur %d)", g.Winner)
+	}
+
+	start := g.replayStart()
+	if _, err := renderGIF(start, g.Moves, g.Eliminations, "classic"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(start.Board, g.Board) || start.Winner != g.Winner {
+		t.Errorf("replay différent de la partie :\n%v\nattendu\n%v", start.Board, g.Board)
+	}
+
+	// Même replay depuis la base, une fois la partie oubliée par le serveur
+	mutex.Lock()
+	settleGame(g)
+	mutex.Unlock()
+	start, moves, eliminations := recordReplay(store, g.ID)
+	if start == nil {
+		t.Fatal("partie absente de la base")
+	}
+	if _, err := renderGIF(start, moves, eliminations, "classic"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(start.Board, g.Board) {
+		t.Errorf("replay de la base différent de la partie :\n%v\nattendu\n%v", start.Board, g.Board)
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "power4-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cfg := defaultConfig()
+	cfg.Storage.Data = filepath.Join(dir, "power4.json")
+	applyConfig(cfg)
+	if err := loadAssets(); err == nil {
+		err = loadTemplates()
+	}
+	if err == nil {
+		store, err = OpenStore(cfg.Storage.Data)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testClient est un navigateur ou un client de l'API : il garde son cookie de session et son
+// jeton anti-CSRF.
+type testClient struct {
+	t      *testing.T
+	cookie *http.Cookie
+	csrf   string
+}
+
+// newTestClient ouvre une session invitée par /api/session, rattachée à la partie game.
+func newTestClient(t *testing.T, game string) *testClient {
+	t.Helper()
+	c := &testClient{t: t}
+	w := c.do(apiSessionHandler, "POST", "/api/session", url.Values{"game": {game}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("ouverture de session : %d %s", w.Code, w.Body)
+	}
+	var s apiSession
+	json.NewDecoder(w.Body).Decode(&s)
+	c.csrf = s.CSRF
+	return c
+}
+
+// do envoie une requête au handler h avec le cookie du client et, sauf si le formulaire porte
+// déjà un champ csrf comme ceux des pages, son jeton en en-tête.
+func (c *testClient) do(h http.HandlerFunc, method, target string, form url.Values) *httptest.ResponseRecorder {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	r := httptest.NewRequest(method, target, body)
+	if form != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.cookie != nil {
+		r.AddCookie(c.cookie)
+	}
+	if c.csrf != "" && !form.Has("csrf") {
+		r.Header.Set("X-CSRF-Token", c.csrf)
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	for _, ck := range w.Result().Cookies() {
+		if ck.Name == sessionCookie {
+			c.cookie = ck
+		}
+	}
+	return w
+}
+
+// newAPIGame crée une partie par l'API et retourne son identifiant.
+func (c *testClient) newAPIGame(params url.Values) string {
+	c.t.Helper()
+	w := c.do(apiGamesHandler, "POST", "/api/games", params)
+	if w.Code != http.StatusCreated {
+		c.t.Fatalf("création de la partie : %d %s", w.Code, w.Body)
+	}
+	var s gameState
+	json.NewDecoder(w.Body).Decode(&s)
+	return s.ID
+}
+
+// play joue à l'indice col dans la partie id par l'API et retourne le code de la réponse.
+func (c *testClient) play(id string, col int) int {
+	return c.do(apiGameHandler, "POST", "/api/game?game="+id, url.Values{"col": {fmt.Sprint(col)}}).Code
+}
+
+func TestAPIWritesRequireCSRF(t *testing.T) {
+	owner := newTestClient(t, "")
+	id := owner.newAPIGame(url.Values{"username": {"alice"}, "username2": {"bob"}})
+
+	anonymous := &testClient{t: t}
+	if code := anonymous.play(id, 0); code != http.StatusForbidden {
+		t.Errorf("coup sans session : %d, attendu 403", code)
+	}
+	forged := &testClient{t: t, cookie: owner.cookie}
+	if code := forged.play(id, 0); code != http.StatusForbidden {
+		t.Errorf("coup sans jeton : %d, attendu 403", code)
+	}
+	if code := anonymous.do(apiGamesHandler, "POST", "/api/games", url.Values{}).Code; code != http.StatusForbidden {
+		t.Errorf("création sans session : %d, attendu 403", code)
+	}
+	if code := owner.play(id, 0); code != http.StatusOK {
+		t.Errorf("coup avec jeton : %d, attendu 200", code)
+	}
+}
+
+func TestGuestSeatsBoundToFirstSession(t *testing.T) {
+	alice := newTestClient(t, "")
+	id := alice.newAPIGame(url.Values{"username": {"alice"}, "username2": {"bob"}})
+	if code := alice.play(id, 0); code != http.StatusOK {
+		t.Fatalf("premier coup : %d", code)
+	}
+	bob := newTestClient(t, id)
+	if code := bob.play(id, 1); code != http.StatusOK {
+		t.Fatalf("premier coup du joueur 2 : %d", code)
+	}
+	// Le joueur 1 est à alice : ni bob ni un tiers avec le lien ne jouent à sa place
+	intruder := newTestClient(t, id)
+	if code := intruder.play(id, 2); code != http.StatusForbidden {
+		t.Errorf("coup d'un tiers pour le joueur 1 : %d, attendu 403", code)
+	}
+	if code := bob.play(id, 2); code != http.StatusForbidden {
+		t.Errorf("coup de bob pour le joueur 1 : %d, attendu 403", code)
+	}
+	if code := alice.play(id, 2); code != http.StatusOK {
+		t.Errorf("coup d'alice : %d, attendu 200", code)
+	}
+	if code := intruder.play(id, 3); code != http.StatusForbidden {
+		t.Errorf("coup d'un tiers pour le joueur 2 : %d, attendu 403", code)
+	}
+
+	// Le formulaire de la page de jeu applique la même règle
+	w := intruder.do(handler, "POST", "/connect4?game="+id, url.Values{"col": {"3"}, "csrf": {intruder.csrf}})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("formulaire d'un tiers : %d, attendu 403", w.Code)
+	}
+	w = bob.do(handler, "POST", "/connect4?game="+id, url.Values{"col": {"3"}, "csrf": {alice.csrf}})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("formulaire avec le jeton d'une autre session : %d, attendu 403", w.Code)
+	}
+	w = bob.do(handler, "POST", "/connect4?game="+id, url.Values{"col": {"3"}, "csrf": {bob.csrf}})
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("formulaire de bob : %d, attendu 303", w.Code)
+	}
+}
+
+func TestHotSeatSessionHoldsBothSeats(t *testing.T) {
+	alice := newTestClient(t, "")
+	id := alice.newAPIGame(url.Values{"username": {"alice"}, "username2": {"bob"}})
+	for col := 0; col < 4; col++ {
+		if code := alice.play(id, col); code != http.StatusOK {
+			t.Fatalf("coup %d sur un même écran : %d", col+1, code)
+		}
+	}
+}
+
+func TestAIMoveOnlyFromPlayerSession(t *testing.T) {
+	alice := newTestClient(t, "")
+	id := alice.newAPIGame(url.Values{"username": {"alice"}, "gamemode": {"ai"}})
+	if code := alice.play(id, 3); code != http.StatusOK {
+		t.Fatalf("coup du joueur : %d", code)
+	}
+	intruder := newTestClient(t, id)
+	w := intruder.do(apiGameHandler, "POST", "/api/game?game="+id, url.Values{"ai": {"1"}})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("coup de l'IA demandé par un tiers (API) : %d, attendu 403", w.Code)
+	}
+	w = intruder.do(aiMoveHandler, "POST", "/ai-move?game="+id, url.Values{"csrf": {intruder.csrf}})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("coup de l'IA demandé par un tiers (/ai-move) : %d, attendu 403", w.Code)
+	}
+	w = alice.do(aiMoveHandler, "POST", "/ai-move?game="+id, url.Values{"csrf": {alice.csrf}})
+	if w.Code != http.StatusOK {
+		t.Errorf("coup de l'IA demandé par le joueur : %d, attendu 200", w.Code)
+	}
+}
+
+func TestIdleGuestSessionsForgotten(t *testing.T) {
+	guest := newTestClient(t, "")
+	mutex.Lock()
+	defer mutex.Unlock()
+	now := time.Now()
+	s := sessions[guest.cookie.Value]
+	user := &Session{ID: "idle-user", User: "alice", Expires: now.Add(sessionLifetime), LastSeen: now.Add(-2 * guestSessionIdle)}
+	sessions[user.ID] = user
+	defer delete(sessions, user.ID)
+
+	s.LastSeen = now.Add(-guestSessionIdle / 2)
+	sweepState(now)
+	if sessions[s.ID] == nil {
+		t.Fatal("session invitée encore utilisée oubliée")
+	}
+	s.LastSeen = now.Add(-guestSessionIdle - time.Minute)
+	r := httptest.NewRequest("GET", "/connect4", nil)
+	r.AddCookie(guest.cookie)
+	if findSession(r) != nil {
+		t.Error("session invitée inactive encore reconnue")
+	}
+	sweepState(now)
+	if sessions[s.ID] != nil {
+		t.Error("session invitée inactive gardée en mémoire")
+	}
+	if sessions[user.ID] == nil {
+		t.Error("session d'un joueur connecté oubliée avant son expiration")
+	}
+}
+
+func TestReplayURLIsAbsolute(t *testing.T) {
+	alice := newTestClient(t, "")
+	id := alice.newAPIGame(url.Values{"username": {"alice"}, "username2": {"bob"}})
+	for _, col := range []int{0, 1, 0, 1, 0, 1, 0} {
+		if code := alice.play(id, col); code != http.StatusOK {
+			t.Fatalf("coup %d : %d", col+1, code)
+		}
+	}
+	result := func(header http.Header) string {
+		r := httptest.NewRequest("GET", "/result?game="+id, nil)
+		r.Header = header
+		r.AddCookie(alice.cookie)
+		w := httptest.NewRecorder()
+		resultHandler(w, r)
+		return w.Body.String()
+	}
+	replay := "/game.gif?game=" + id
+	if body := result(http.Header{}); !strings.Contains(body, `value="http://example.com`+replay+`"`) {
+		t.Errorf("lien de replay sans l'adresse de la requête :\n%s", body)
+	}
+
+	defer func(url string, proxy bool) { publicURL, trustProxy = url, proxy }(publicURL, trustProxy)
+	trustProxy = true
+	if body := result(http.Header{"X-Forwarded-Proto": {"https"}}); !strings.Contains(body, `value="https://example.com`+replay+`"`) {
+		t.Error("schéma annoncé par le proxy ignoré")
+	}
+	trustProxy = false
+	if body := result(http.Header{"X-Forwarded-Proto": {"https"}}); !strings.Contains(body, `value="http://example.com`+replay+`"`) {
+		t.Error("X-Forwarded-Proto suivi sans proxy de confiance")
+	}
+	publicURL = "https://power4.example.org"
+	if body := result(http.Header{}); !strings.Contains(body, `value="https://power4.example.org`+replay+`"`) {
+		t.Error("adresse publique configurée ignorée")
+	}
+}
+
+// newAccountClient enregistre le compte name et ouvre une session connectée par /api/session.
+func newAccountClient(t *testing.T, name string) *testClient {
+	t.Helper()
+	if _, err := registerUser(name, "motdepasse"); err != nil {
+		t.Fatal(err)
+	}
+	c := &testClient{t: t}
+	w := c.do(apiSessionHandler, "POST", "/api/session", url.Values{"name": {name}, "password": {"motdepasse"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("connexion de %s : %d %s", name, w.Code, w.Body)
+	}
+	var s apiSession
+	json.NewDecoder(w.Body).Decode(&s)
+	c.csrf = s.CSRF
+	return c
+}
+
+func TestAdminRequiresAccountFlag(t *testing.T) {
+	admin := newAccountClient(t, "gerant")
+	if code := admin.do(adminHandler, "GET", "/admin", nil).Code; code != http.StatusForbidden {
+		t.Errorf("/admin sans le droit : %d, attendu 403", code)
+	}
+	if err := adminCommand([]string{"gerant"}); err != nil {
+		t.Fatal(err)
+	}
+	// La commande écrit la base du disque : le serveur la relit à son démarrage
+	reopenStore(t)
+	if code := admin.do(adminHandler, "GET", "/admin", nil).Code; code != http.StatusOK {
+		t.Errorf("/admin avec le droit : %d, attendu 200", code)
+	}
+	if err := adminCommand([]string{"inconnu"}); err == nil {
+		t.Error("droit accordé à un compte inexistant")
+	}
+}
+
+func TestAdminEndSettlesGame(t *testing.T) {
+	admin := newAccountClient(t, "arbitre")
+	if err := adminCommand([]string{"arbitre"}); err != nil {
+		t.Fatal(err)
+	}
+	reopenStore(t)
+	alice := newAccountClient(t, "joueuse1")
+	bob := newAccountClient(t, "joueuse2")
+	id := alice.newAPIGame(url.Values{"username": {"joueuse1"}, "username2": {"joueuse2"}})
+	bob.do(apiSessionHandler, "POST", "/api/session", url.Values{"game": {id}})
+	if code := alice.play(id, 0); code != http.StatusOK {
+		t.Fatalf("premier coup : %d", code)
+	}
+
+	w := admin.do(adminHandler, "POST", "/admin", url.Values{"action": {"end"}, "game": {id}, "csrf": {admin.csrf}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("fin de partie par l'administration : %d %s", w.Code, w.Body)
+	}
+	mutex.Lock()
+	g := games[id]
+	ended := g.GameOver && g.Recorded && g.Aborted && !g.Ended.IsZero()
+	mutex.Unlock()
+	if !ended {
+		t.Fatal("partie close sans passer par l'enregistrement du résultat")
+	}
+	var rec *GameRecord
+	store.View(func(d *storeData) {
+		for _, r := range d.Results {
+			if r.ID == id {
+				rec = r
+			}
+		}
+		if d.Ratings["joueuse1"] != nil {
+			t.Error("partie close comptée au classement")
+		}
+	})
+	if rec == nil || !rec.Aborted {
+		t.Fatalf("partie close absente de l'historique (%+v)", rec)
+	}
+	if stats := computeStats([]*GameRecord{rec}); len(stats) != 0 {
+		t.Errorf("partie close comptée dans les statistiques : %v", stats)
+	}
+}
+
+// reopenStore relit la base depuis le disque.
+func reopenStore(t *testing.T) {
+	t.Helper()
+	s, err := OpenStore(dataPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store = s
+}
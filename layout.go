@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"power4/engine"
+)
+
+// Les noms de plateau servent de nom de fichier : on n'accepte que des caractères sûrs.
+var layoutNameRe = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// layoutFS retourne les plateaux de départ embarqués (layouts/*.txt), ou le dossier
+// game.layouts en mode -dev.
+func layoutFS() fs.FS {
+	if config.Server.Dev {
+		return os.DirFS(config.Game.Layouts)
+	}
+	sub, _ := fs.Sub(embeddedFiles, "layouts") // Dossier embarqué : pas d'erreur possible
+	return sub
+}
+
+// loadLayout charge le plateau <name>.txt.
+func loadLayout(name string) (*engine.Layout, error) {
+	if !layoutNameRe.MatchString(name) {
+		return nil, fmt.Errorf("nom de plateau %q invalide", name)
+	}
+	f, err := layoutFS().Open(name + ".txt")
+	if err != nil {
+		return nil, fmt.Errorf("plateau %q introuvable", name)
+	}
+	defer f.Close()
+	return engine.ParseLayout(name, f)
+}
+
+// listLayouts retourne les noms des plateaux disponibles, triés.
+func listLayouts() []string {
+	files, _ := fs.Glob(layoutFS(), "*.txt")
+	var names []string
+	for _, f := range files {
+		name := strings.TrimSuffix(f, ".txt")
+		if layoutNameRe.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyLayout remplace le plateau de la partie par la position de départ l et retient son nom.
+func (g *Game) applyLayout(l *engine.Layout) error {
+	if err := g.ApplyLayout(l); err != nil {
+		return err
+	}
+	g.Layout = l.Name
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Journal du serveur (log/slog), en texte ou en JSON (server.log, POWER4_LOG=json). Chaque requête
+// reçoit un identifiant (repris de l'en-tête X-Request-ID s'il est fourni) renvoyé au client
+// et joint à toutes les lignes écrites pendant son traitement.
+
+// Identifiant de requête accepté depuis l'en-tête X-Request-ID d'un proxy.
+var requestIDRe = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type loggerKey struct{}
+
+// newLogger crée le journal du serveur au format donné (text ou json), sur la sortie d'erreur.
+func newLogger(format string) *slog.Logger {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, nil))
+}
+
+// fatal journalise une erreur qui empêche le serveur de tourner, puis quitte.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+// requestLogger retourne le journal de la requête, qui porte son identifiant.
+func requestLogger(r *http.Request) *slog.Logger {
+	if l, ok := r.Context().Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// statusWriter retient le code et la taille de la réponse pour le journal.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Flush laisse passer les flux d'événements.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap donne accès à la connexion sous-jacente (http.ResponseController).
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logRequests attribue un identifiant à chaque requête et la journalise une fois servie.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRe.MatchString(id) {
+			id = newID(8)
+		}
+		w.Header().Set("X-Request-ID", id)
+		logger := slog.Default().With("request_id", id)
+		r = r.WithContext(context.WithValue(r.Context(), loggerKey{}, logger))
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		httpRequests.inc(strconv.Itoa(sw.status))
+		logger.Info("requête",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"bytes", sw.bytes,
+			"duration", time.Since(start),
+			"ip", clientIP(r),
+		)
+	})
+}
+
+// render exécute un template de page et journalise un échec (la réponse est alors tronquée).
+// En mode -dev, le template est relu sur le disque pour prendre en compte les modifications.
+func render(w http.ResponseWriter, r *http.Request, tmpl *template.Template, data any) {
+	if config.Server.Dev {
+		fresh, err := parseTemplate(tmpl.Name())
+		if err != nil {
+			requestLogger(r).Error("lecture du template", "template", tmpl.Name(), "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		tmpl = fresh
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		requestLogger(r).Error("affichage de la page", "template", tmpl.Name(), "err", err)
+	}
+}
+
+// movePlayed journalise et compte le coup que vient de jouer player.
+func movePlayed(r *http.Request, g *Game, player int) {
+	countMove(g, player)
+	requestLogger(r).Info("coup joué",
+		"game", g.ID,
+		"player", player,
+		"index", g.Moves[len(g.Moves)-1],
+		"row", g.LastRow,
+		"col", g.LastCol,
+		"turn", g.TurnCount,
+		"game_over", g.GameOver,
+	)
+}
+
+// aiDecision choisit le coup de l'IA et journalise la décision : niveau, profondeur de
+// recherche et durée. Doit être appelé avec mutex verrouillé : la recherche se fait sur une
+// copie de la partie, verrou relâché, pour ne pas bloquer les autres requêtes. Retourne -1 si
+// la partie a changé entre-temps (coup déjà joué par une autre requête, partie terminée).
+func aiDecision(r *http.Request, g *Game) int {
+	snapshot, version, level := g.Game.Clone(), g.Version, g.AILevel
+	depth := snapshot.SearchDepth(level)
+	mutex.Unlock()
+	start := time.Now()
+	index := snapshot.AIMove(level)
+	elapsed := time.Since(start)
+	mutex.Lock()
+	aiLatency.observe(level.String(), elapsed)
+	stale := g.Version != version || g.GameOver || games[g.ID] != g
+	requestLogger(r).Info("décision de l'IA",
+		"game", g.ID,
+		"level", level.String(),
+		"depth", depth,
+		"index", index,
+		"duration", elapsed,
+		"stale", stale,
+	)
+	if stale {
+		return -1
+	}
+	return index
+}
@@ -1,21 +1,21 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
+	"log/slog"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
-)
-
-type Gravity int
 
-const (
-	GravityDown Gravity = iota
-	GravityUp
+	"power4/engine"
 )
 
 type GameMode int
@@ -25,530 +25,131 @@ const (
 	ModeHumanVsAI
 )
 
-type AILevel int
-
-const (
-	AIEasy AILevel = iota
-	AIMedium
-	AIHard
-)
+// String retourne le nom du mode dans les paramètres de partie (gamemode=human ou ai).
+func (m GameMode) String() string {
+	if m == ModeHumanVsAI {
+		return "ai"
+	}
+	return "human"
+}
 
-// Ajoute un champ Mode à Game pour retenir le mode de jeu
+// Game est une partie hébergée par le serveur : l'état des règles (plateau, gravité, pendule,
+// coups joués) vient du moteur, le reste décrit les joueurs et la place de la partie sur le site.
 type Game struct {
-	Board         [][]int
-	Rows, Cols    int
-	CurrentPlayer int
-	Winner        int
-	GameOver      bool
-	LastRow       int
-	LastCol       int
-	TurnCount     int
-	Gravity       Gravity
+	engine.Game
 	Difficulty    string
 	Username      string // kept for backward compatibility
 	Username1     string
 	Username2     string
-	Mode          string // "normal" ou "inverse"
 	GameMode      GameMode
-	AILevel       AILevel
-	Skin          string // Nom du skin sélectionné
+	AILevel       engine.AILevel
+	Skin          string   // Nom du skin sélectionné
+	Layout        string   // Nom du plateau personnalisé ("" si aucun)
+	Usernames     []string // Noms de tous les joueurs, index 0 = joueur 1
+	Colors        []string // Couleur de jeton de chaque joueur
+	ID            string
+	Params        string         // Paramètres de création, réutilisés pour la revanche
+	Accounts      []string       // Compte lié à chaque siège ("" pour un invité)
+	Guests        []string       `json:"-"` // Session qui a pris chaque siège invité ("" tant que personne n'y a joué)
+	Next          string         // Identifiant de la revanche, pour y emmener tous les joueurs
+	Started       time.Time      // Création de la partie
+	Ended         time.Time      // Fin de la partie (zéro tant qu'elle continue)
+	Recorded      bool           // Résultat déjà enregistré dans les statistiques
+	Aborted       bool           // Close sans résultat par l'administration
+	RatingChanges []RatingChange // Variation des classements Elo en fin de partie classée
+	Tournament    string         // Tournoi de la partie ("" hors tournoi)
+	Match         int            // Rencontre du tournoi jouée par cette partie
+	Series        *Series        // Série en plusieurs manches (nil pour une partie unique)
+	Version       int            // Incrémenté à chaque changement signalé aux clients (notifyGame)
+	Active        time.Time      // Dernier changement signalé aux clients (voir cleanup.go)
 }
 
 var (
-	game  *Game
+	games = map[string]*Game{} // Parties en cours, par identifiant
 	mutex sync.Mutex
 )
 
-// Durée du délai en millisecondes entre le coup du joueur et celui de l'IA
-var aiDelayMs = 1000
+// Tuning regroupe les réglages de jeu modifiables à chaud depuis la page d'administration.
+type Tuning struct {
+	AIDelayMs int // Délai en millisecondes entre le coup du joueur et celui de l'IA
+}
+
+// Réglages en vigueur, protégés par mutex comme les parties.
+var tuning = Tuning{AIDelayMs: config.AI.DelayMs}
 
-func NewGame(rows, cols, prefill int, difficulty, username1, username2, mode, skin string, gameMode GameMode, aiLevel AILevel) *Game {
-	board := make([][]int, rows)
-	for i := range board {
-		board[i] = make([]int, cols)
-	}
-	// Prefill random cells
+func NewGame(rows, cols, prefill, obstacles int, difficulty, username1, username2, mode, skin string, gameMode GameMode, aiLevel engine.AILevel) *Game {
+	g := engine.New(rows, cols, mode)
+	// Pré-remplissage aléatoire validé (gravité respectée, aucun alignement existant)
 	source := rand.NewSource(time.Now().UnixNano())
 	rng := rand.New(source)
-	for n := 0; n < prefill; {
-		r := rng.Intn(rows)
-		c := rng.Intn(cols)
-		if board[r][c] == 0 {
-			board[r][c] = rng.Intn(2) + 1
-			n++
-		}
-	}
-	gravity := GravityDown
-	if mode == "inverse" {
-		gravity = GravityUp
-	} else {
-		gravity = GravityDown
-	}
+	g.Board = engine.RandomLayout(rows, cols, prefill, obstacles, 2, g.Gravity, rng)
+	g.CurrentPlayer = engine.StartingPlayer(g.Board, 2)
+	g.MaxDepth = config.AI.Depth
 	if gameMode == ModeHumanVsAI && username2 == "" {
 		username2 = "IA"
 	}
 	return &Game{
-		Board:         board,
-		Rows:          rows,
-		Cols:          cols,
-		CurrentPlayer: 1,
-		Winner:        0,
-		GameOver:      false,
-		LastRow:       -1,
-		LastCol:       -1,
-		TurnCount:     0,
-		Gravity:       gravity,
-		Difficulty:    difficulty,
-		Username:      username1,
-		Username1:     username1,
-		Username2:     username2,
-		Mode:          mode,
-		GameMode:      gameMode,
-		AILevel:       aiLevel,
-		Skin:          skin,
-	}
-}
-
-// DropToken now supports gravity direction and increments turn count.
-func (g *Game) DropToken(col int) bool {
-	if col < 0 || col >= g.Cols || g.GameOver {
-		return false
-	}
-	var row int
-	if g.Gravity == GravityDown {
-		for row = g.Rows - 1; row >= 0; row-- {
-			if g.Board[row][col] == 0 {
-				break
-			}
-		}
-	} else {
-		for row = 0; row < g.Rows; row++ {
-			if g.Board[row][col] == 0 {
-				break
-			}
-		}
-	}
-	if row < 0 || row >= g.Rows || g.Board[row][col] != 0 {
-		return false
-	}
-	g.Board[row][col] = g.CurrentPlayer
-	g.LastRow = row
-	g.LastCol = col
-	g.TurnCount++
-	// Gravity reversal every 5 turns - only in inverse mode
-	if g.Mode == "inverse" && g.TurnCount%5 == 0 {
-		if g.Gravity == GravityDown {
-			g.Gravity = GravityUp
-		} else {
-			g.Gravity = GravityDown
-		}
-	}
-	if g.checkWin(row, col) {
-		g.Winner = g.CurrentPlayer
-		g.GameOver = true
-	} else if g.isDraw() {
-		g.GameOver = true
-	}
-	g.CurrentPlayer = 3 - g.CurrentPlayer
-	return true
-}
-
-// checkWin vérifie si le dernier coup joué (row, col) crée un alignement de 4 jetons de même couleur.
-func (g *Game) checkWin(row, col int) bool {
-	player := g.Board[row][col]
-	dirs := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
-	for _, d := range dirs {
-		count := 1
-		for i := 1; i < 4; i++ {
-			r := row + d[0]*i
-			c := col + d[1]*i
-			if r >= 0 && r < g.Rows && c >= 0 && c < g.Cols && g.Board[r][c] == player {
-				count++
-			} else {
-				break
-			}
-		}
-		for i := 1; i < 4; i++ {
-			r := row - d[0]*i
-			c := col - d[1]*i
-			if r >= 0 && r < g.Rows && c >= 0 && c < g.Cols && g.Board[r][c] == player {
-				count++
-			} else {
-				break
-			}
-		}
-		if count >= 4 {
-			return true
-		}
-	}
-	return false
-}
-
-// isDraw vérifie si le plateau est plein (aucune case vide en haut de chaque colonne).
-func (g *Game) isDraw() bool {
-	for c := 0; c < g.Cols; c++ {
-		if g.Board[0][c] == 0 {
-			return false
-		}
-	}
-	return true
-}
-
-// AI Functions
-
-// getValidMoves retourne les colonnes où il est possible de jouer
-func (g *Game) getValidMoves() []int {
-	var moves []int
-	for col := 0; col < g.Cols; col++ {
-		// Vérifie si la colonne n'est pas pleine
-		var canPlay bool
-		if g.Gravity == GravityDown {
-			canPlay = g.Board[0][col] == 0
-		} else {
-			canPlay = g.Board[g.Rows-1][col] == 0
-		}
-		if canPlay {
-			moves = append(moves, col)
-		}
-	}
-	return moves
-}
-
-// checkWinningMove vérifie si jouer dans une colonne ferait gagner le joueur
-func (g *Game) checkWinningMove(col, player int) bool {
-	// Simule le coup
-	var row int
-	if g.Gravity == GravityDown {
-		for row = g.Rows - 1; row >= 0; row-- {
-			if g.Board[row][col] == 0 {
-				break
-			}
-		}
-	} else {
-		for row = 0; row < g.Rows; row++ {
-			if g.Board[row][col] == 0 {
-				break
-			}
-		}
-	}
-
-	if row < 0 || row >= g.Rows || g.Board[row][col] != 0 {
-		return false
-	}
-
-	// Place temporairement le jeton
-	g.Board[row][col] = player
-	win := g.checkWin(row, col)
-	g.Board[row][col] = 0 // Retire le jeton
-
-	return win
-}
-
-// aiEasyMove - IA facile : joue aléatoirement
-func (g *Game) aiEasyMove() int {
-	moves := g.getValidMoves()
-	if len(moves) == 0 {
-		return -1
-	}
-	return moves[rand.Intn(len(moves))]
-}
-
-// aiMediumMove - IA moyenne : bloque les victoires adverses et cherche ses victoires
-func (g *Game) aiMediumMove() int {
-	moves := g.getValidMoves()
-	if len(moves) == 0 {
-		return -1
-	}
-
-	// 1. Cherche un coup gagnant pour l'IA (joueur 2)
-	for _, col := range moves {
-		if g.checkWinningMove(col, 2) {
-			return col
-		}
-	}
-
-	// 2. Bloque un coup gagnant de l'adversaire (joueur 1)
-	for _, col := range moves {
-		if g.checkWinningMove(col, 1) {
-			return col
-		}
-	}
-
-	// 3. Sinon, joue aléatoirement
-	return moves[rand.Intn(len(moves))]
-}
-
-// aiHardMove - IA difficile : utilise minimax
-func (g *Game) aiHardMove() int {
-	moves := g.getValidMoves()
-	if len(moves) == 0 {
-		return -1
-	}
-
-	// Utilise minimax avec une profondeur limitée
-	_, bestCol := g.minimax(4, true, -1000, 1000)
-
-	// Fallback au cas où minimax échoue
-	if bestCol == -1 && len(moves) > 0 {
-		return moves[0]
-	}
-
-	return bestCol
-}
-
-// minimax - Algorithme minimax avec élagage alpha-beta
-func (g *Game) minimax(depth int, isMaximizing bool, alpha, beta int) (int, int) {
-	// Conditions de fin
-	if depth == 0 || g.GameOver {
-		return g.evaluateBoard(), -1
-	}
-
-	moves := g.getValidMoves()
-	if len(moves) == 0 {
-		return 0, -1 // Match nul
-	}
-
-	bestCol := moves[0]
-
-	if isMaximizing {
-		maxEval := -1000
-		for _, col := range moves {
-			// Simule le coup
-			row := g.simulateMove(col, 2)
-			if row == -1 {
-				continue
-			}
-
-			eval, _ := g.minimax(depth-1, false, alpha, beta)
-			g.Board[row][col] = 0 // Annule le coup
-
-			if eval > maxEval {
-				maxEval = eval
-				bestCol = col
-			}
-
-			alpha = max(alpha, eval)
-			if beta <= alpha {
-				break // Élagage alpha-beta
-			}
-		}
-		return maxEval, bestCol
-	} else {
-		minEval := 1000
-		for _, col := range moves {
-			// Simule le coup
-			row := g.simulateMove(col, 1)
-			if row == -1 {
-				continue
-			}
-
-			eval, _ := g.minimax(depth-1, true, alpha, beta)
-			g.Board[row][col] = 0 // Annule le coup
-
-			if eval < minEval {
-				minEval = eval
-				bestCol = col
-			}
-
-			beta = min(beta, eval)
-			if beta <= alpha {
-				break // Élagage alpha-beta
-			}
-		}
-		return minEval, bestCol
-	}
-}
-
-// simulateMove simule un coup sans vérifier les conditions de victoire
-func (g *Game) simulateMove(col, player int) int {
-	var row int
-	if g.Gravity == GravityDown {
-		for row = g.Rows - 1; row >= 0; row-- {
-			if g.Board[row][col] == 0 {
-				break
-			}
-		}
-	} else {
-		for row = 0; row < g.Rows; row++ {
-			if g.Board[row][col] == 0 {
-				break
-			}
-		}
-	}
-
-	if row < 0 || row >= g.Rows || g.Board[row][col] != 0 {
-		return -1
-	}
-
-	g.Board[row][col] = player
-	return row
-}
-
-// evaluateBoard évalue la position pour l'IA (joueur 2)
-func (g *Game) evaluateBoard() int {
-	score := 0
-
-	// Vérifie toutes les fenêtres de 4 cases
-	for r := 0; r < g.Rows; r++ {
-		for c := 0; c < g.Cols; c++ {
-			// Horizontal
-			if c+3 < g.Cols {
-				score += g.evaluateWindow(r, c, 0, 1)
-			}
-			// Vertical
-			if r+3 < g.Rows {
-				score += g.evaluateWindow(r, c, 1, 0)
-			}
-			// Diagonale descendante
-			if r+3 < g.Rows && c+3 < g.Cols {
-				score += g.evaluateWindow(r, c, 1, 1)
-			}
-			// Diagonale montante
-			if r+3 < g.Rows && c-3 >= 0 {
-				score += g.evaluateWindow(r, c, 1, -1)
-			}
-		}
-	}
-
-	return score
-}
-
-// evaluateWindow évalue une fenêtre de 4 cases
-func (g *Game) evaluateWindow(startR, startC, deltaR, deltaC int) int {
-	score := 0
-	aiCount := 0
-	humanCount := 0
-
-	for i := 0; i < 4; i++ {
-		r := startR + i*deltaR
-		c := startC + i*deltaC
-
-		if g.Board[r][c] == 2 {
-			aiCount++
-		} else if g.Board[r][c] == 1 {
-			humanCount++
-		}
-	}
-
-	// Ne peut pas être une ligne gagnante si les deux joueurs y ont des jetons
-	if aiCount > 0 && humanCount > 0 {
-		return 0
-	}
-
-	// Évaluation pour l'IA (joueur 2)
-	if aiCount == 4 {
-		score += 100
-	} else if aiCount == 3 {
-		score += 10
-	} else if aiCount == 2 {
-		score += 2
-	}
-
-	// Évaluation contre l'humain (joueur 1)
-	if humanCount == 4 {
-		score -= 100
-	} else if humanCount == 3 {
-		score -= 10
-	} else if humanCount == 2 {
-		score -= 2
-	}
-
-	return score
-}
-
-// Fonctions utilitaires pour min/max
-func max(a, b int) int {
-	if a > b {
-		return a
+		Game:       *g,
+		Difficulty: difficulty,
+		Username:   username1,
+		Username1:  username1,
+		Username2:  username2,
+		GameMode:   gameMode,
+		AILevel:    aiLevel,
+		Skin:       skin,
+		Usernames:  []string{username1, username2},
+		Colors:     []string{"red", "yellow"},
+		ID:         newID(8),
+		Accounts:   make([]string, 2),
+		Started:    time.Now(),
 	}
-	return b
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-// aiMove choisit le coup de l'IA selon son niveau
+// aiMove choisit le coup de l'IA selon le niveau de la partie.
 func (g *Game) aiMove() int {
-	switch g.AILevel {
-	case AIEasy:
-		return g.aiEasyMove()
-	case AIMedium:
-		return g.aiMediumMove()
-	case AIHard:
-		return g.aiHardMove()
-	default:
-		return g.aiEasyMove()
-	}
-}
-
-// getWinningPositions retourne les positions des 4 jetons gagnants si victoire, sinon nil.
-func (g *Game) getWinningPositions() [][2]int {
-	player := g.Winner
-	if player == 0 {
-		return nil
-	}
-	dirs := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
-	for r := 0; r < g.Rows; r++ {
-		for c := 0; c < g.Cols; c++ {
-			if g.Board[r][c] != player {
-				continue
-			}
-			for _, d := range dirs {
-				positions := [][2]int{{r, c}}
-				for i := 1; i < 4; i++ {
-					r2 := r + d[0]*i
-					c2 := c + d[1]*i
-					if r2 >= 0 && r2 < g.Rows && c2 >= 0 && c2 < g.Cols && g.Board[r2][c2] == player {
-						positions = append(positions, [2]int{r2, c2})
-					} else {
-						break
-					}
-				}
-				if len(positions) == 4 {
-					return positions
-				}
-			}
-		}
-	}
-	return nil
+	return g.AIMove(g.AILevel)
 }
 
 // renderBoard génère le HTML du plateau et permet la sélection de colonne par clic sur la flèche au-dessus de chaque colonne.
 // Les boutons de colonne ont été remplacés par cette interaction directe, plus intuitive.
-func renderBoard(g *Game) template.HTML {
-	playerClass := "p1"
-	if g.CurrentPlayer == 2 {
-		playerClass = "p2"
-	}
+// csrf est le jeton de la session, joint au formulaire (game.js le reprend pour le coup de l'IA).
+func renderBoard(g *Game, csrf string) template.HTML {
+	playerClass := "p" + strconv.Itoa(g.CurrentPlayer) + " c-" + g.colorOf(g.CurrentPlayer)
 
 	// Désactive l'interface si c'est le tour de l'IA
 	disableInterface := g.GameMode == ModeHumanVsAI && g.CurrentPlayer == 2 && !g.GameOver
 	// Plus de flèches directionnelles: clic direct sur la colonne
 	winning := map[[2]int]bool{}
 	if g.GameOver && g.Winner != 0 {
-		for _, pos := range g.getWinningPositions() {
+		for _, pos := range g.WinningPositions() {
 			winning[pos] = true
 		}
 	}
-	html := "<form method='POST' id='board-form'><input type='hidden' name='col' id='col-input'/>\n"
-	html += "<div class='board-wrap " + playerClass
-	if g.Gravity == GravityUp {
-		html += " gravity-up"
-	} else {
-		html += " gravity-down"
-	}
+	html := "<form method='POST' id='board-form'><input type='hidden' name='col' id='col-input'/>"
+	html += "<input type='hidden' name='csrf' value='" + csrf + "'/>\n"
+	html += "<div class='board-wrap " + playerClass + " gravity-" + g.Gravity.String()
 	html += "' id='board-wrap' style='overflow-x:auto; max-width:100vw;'>\n"
-	html += "<table class='board' id='board' data-gameover='"
+	// data-axis indique si un clic choisit une colonne ou une ligne d'entrée
+	axis := "col"
+	if g.Gravity.Horizontal() {
+		axis = "row"
+	}
+	html += "<table class='board' id='board' data-axis='" + axis + "' data-gameover='"
 	if g.GameOver {
 		html += "1'"
 	} else {
 		html += "0'"
 	}
-	html += " data-current='" + strconv.Itoa(g.CurrentPlayer) + "' style='margin:auto;'>\n"
+	html += " data-current='" + strconv.Itoa(g.CurrentPlayer) + "'"
+	// Comportement du plateau, mis en place par game.js : clic sur une colonne (ou une ligne en
+	// gravité latérale) pour jouer, ou coup de l'IA demandé à /ai-move après un délai
+	if !g.GameOver && !disableInterface {
+		html += " data-play='1'"
+	}
+	if g.GameMode == ModeHumanVsAI && g.CurrentPlayer == 2 && !g.GameOver {
+		html += " data-game='" + g.ID + "' data-ai-delay='" + strconv.Itoa(g.aiDelay()) + "'"
+	}
+	html += " style='margin:auto;'>\n"
 
 	// Suppression de la ligne de sélection: on clique désormais directement sur une colonne du plateau
 
@@ -565,57 +166,27 @@ func renderBoard(g *Game) template.HTML {
 			if g.LastRow == r && g.LastCol == c {
 				wrapCls = " just-played"
 			}
-			switch g.Board[r][c] {
-			case 1:
-				cell = "<div class='token-wrap" + wrapCls + "'><div class='token red" + tokenCls + "'></div></div>"
-			case 2:
-				cell = "<div class='token-wrap" + wrapCls + "'><div class='token yellow" + tokenCls + "'></div></div>"
+			switch v := g.Board[r][c]; {
+			case v > 0:
+				cell = "<div class='token-wrap" + wrapCls + "'><div class='token " + g.colorOf(v) + tokenCls + "'></div></div>"
+			case v == engine.Obstacle:
+				cell = "<div class='token-wrap'><div class='token obstacle'></div></div>"
 			}
-			html += "<td data-col='" + strconv.Itoa(c) + "'>" + cell + "</td>"
+			html += "<td data-col='" + strconv.Itoa(c) + "' data-row='" + strconv.Itoa(r) + "'>" + cell + "</td>"
 		}
 		html += "</tr>"
 	}
 	html += "</table>\n"
 	html += "</div>" // end board-wrap
 	html += "<div class='controls'><button name='reset' value='1'>Nouvelle partie</button>"
+	if g.Players > 2 && !g.GameOver {
+		html += "<button name='resign' value='1'>Abandonner</button>"
+	}
 	if g.GameOver {
-		html += "<button name='rematch' value='1'>Revanche</button>"
+		html += "<button name='rematch' value='1'>" + g.rematchLabel() + "</button>"
 	}
 	html += "</div></form>"
 
-	// JS pour gérer le clic directement sur une colonne du plateau et la surbrillance au survol
-	if !g.GameOver && !disableInterface {
-		html += `<script>
-		(function(){
-			var form = document.getElementById('board-form');
-			var colInput = document.getElementById('col-input');
-			function setColHighlight(col, on){
-				document.querySelectorAll('#board td[data-col="' + col + '"]').forEach(function(td){
-					if(on){ td.classList.add('col-selected'); } else { td.classList.remove('col-selected'); }
-				});
-			}
-			document.querySelectorAll('#board td').forEach(function(td){
-				var col = td.getAttribute('data-col');
-				if(col === null) return;
-				td.addEventListener('mouseenter', function(){ setColHighlight(col, true); });
-				td.addEventListener('mouseleave', function(){ setColHighlight(col, false); });
-				td.addEventListener('click', function(){
-					colInput.value = col;
-					form.submit();
-				});
-			});
-		})();
-		</script>`
-	}
-
-	// Si c'est au tour de l'IA en mode Humain vs IA, on lance un fetch vers /ai-move après un délai
-	if g.GameMode == ModeHumanVsAI && g.CurrentPlayer == 2 && !g.GameOver {
-		// expose le délai en ms via data attribute et lance le fetch après le délai
-		html += "<script>" +
-			"(function(){ var delay=" + strconv.Itoa(aiDelayMs) + "; setTimeout(function(){ fetch('/ai-move', {method: 'POST'}).then(function(){ window.location.reload(); }); }, delay); })();" +
-			"</script>"
-	}
-
 	return template.HTML(html)
 }
 
@@ -626,163 +197,280 @@ var (
 	winTmpl   *template.Template
 	loseTmpl  *template.Template
 	modeTmpl  *template.Template
+
+	accountTmpl *template.Template
+	profileTmpl *template.Template
+
+	leaderboardTmpl *template.Template
+	statsTmpl       *template.Template
+	matchmakingTmpl *template.Template
+	tournamentsTmpl *template.Template
+	tournamentTmpl  *template.Template
+	errorTmpl       *template.Template
+	adminTmpl       *template.Template
 )
 
 func loadTemplates() error {
 	var err error
-	pageTmpl, err = template.ParseFiles("templates/game.html")
+	pageTmpl, err = parseTemplate("game.html")
+	if err != nil {
+		return err
+	}
+	startTmpl, err = parseTemplate("start.html")
+	if err != nil {
+		return err
+	}
+	winTmpl, err = parseTemplate("win.html")
+	if err != nil {
+		return err
+	}
+	loseTmpl, err = parseTemplate("lose.html")
 	if err != nil {
 		return err
 	}
-	startTmpl, err = template.ParseFiles("templates/start.html")
+	modeTmpl, err = parseTemplate("mode.html")
 	if err != nil {
 		return err
 	}
-	winTmpl, err = template.ParseFiles("templates/win.html")
+	accountTmpl, err = parseTemplate("account.html")
 	if err != nil {
 		return err
 	}
-	loseTmpl, err = template.ParseFiles("templates/lose.html")
+	profileTmpl, err = parseTemplate("profile.html")
 	if err != nil {
 		return err
 	}
-	modeTmpl, err = template.ParseFiles("templates/mode.html")
+	leaderboardTmpl, err = parseTemplate("leaderboard.html")
+	if err != nil {
+		return err
+	}
+	statsTmpl, err = parseTemplate("stats.html")
+	if err != nil {
+		return err
+	}
+	matchmakingTmpl, err = parseTemplate("matchmaking.html")
+	if err != nil {
+		return err
+	}
+	tournamentsTmpl, err = parseTemplate("tournaments.html")
+	if err != nil {
+		return err
+	}
+	tournamentTmpl, err = parseTemplate("tournament.html")
+	if err != nil {
+		return err
+	}
+	errorTmpl, err = parseTemplate("error.html")
+	if err != nil {
+		return err
+	}
+	adminTmpl, err = parseTemplate("admin.html")
 	return err
 }
 
 // --- Nouveau handler pour choisir le mode ---
 func modeHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	settings, err := parseSettings(r.Form)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
 	if r.Method == "POST" {
-		mode := r.FormValue("mode")
-		username := r.FormValue("username")
-		username2 := r.FormValue("username2")
-		difficulty := r.FormValue("difficulty")
-		skin := r.FormValue("skin") // Ajout du skin
-		gamemode := r.FormValue("gamemode")
-		ailevel := r.FormValue("ailevel")
-
-		url := "/connect4?username=" + username + "&difficulty=" + difficulty + "&mode=" + mode + "&skin=" + skin + "&gamemode=" + gamemode
-		if username2 != "" {
-			url += "&username2=" + username2
-		}
-		if ailevel != "" {
-			url += "&ailevel=" + ailevel
-		}
-
-		http.Redirect(w, r, url, http.StatusSeeOther)
+		http.Redirect(w, r, "/connect4?"+settings.Values().Encode(), http.StatusSeeOther)
 		return
 	}
-	// On récupère tous les paramètres pour les garder dans le formulaire
-	username := r.URL.Query().Get("username")
-	username2 := r.URL.Query().Get("username2")
-	difficulty := r.URL.Query().Get("difficulty")
-	skin := r.URL.Query().Get("skin") // Ajout du skin
-	gamemode := r.URL.Query().Get("gamemode")
-	ailevel := r.URL.Query().Get("ailevel")
-
-	modeTmpl.Execute(w, map[string]interface{}{
-		"Username":   username,
-		"Username2":  username2,
-		"Difficulty": difficulty,
-		"Skin":       skin, // Ajout du skin
-		"GameMode":   gamemode,
-		"AILevel":    ailevel,
+	// On garde tous les réglages dans le formulaire ; le mode est choisi par le bouton
+	params := settings.Values()
+	params.Del("mode")
+	render(w, r, modeTmpl, map[string]interface{}{
+		"Params": params,
 	})
 }
 
 // --- Modifie startHandler pour rediriger vers /mode ---
 func startHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
-		username := r.FormValue("username")
-		username2 := r.FormValue("username2")
-		difficulty := r.FormValue("difficulty")
-		skin := r.FormValue("skin")
-		gamemode := r.FormValue("gamemode")
-		ailevel := r.FormValue("ailevel")
-
-		url := "/mode?username=" + username + "&difficulty=" + difficulty + "&skin=" + skin + "&gamemode=" + gamemode
-		if username2 != "" {
-			url += "&username2=" + username2
-		}
-		if ailevel != "" {
-			url += "&ailevel=" + ailevel
+		r.ParseForm()
+		settings, err := parseSettings(r.Form)
+		if err != nil {
+			renderError(w, r, http.StatusBadRequest, err.Error())
+			return
 		}
-
-		http.Redirect(w, r, url, http.StatusSeeOther)
+		params := settings.Values()
+		params.Del("mode")
+		http.Redirect(w, r, "/mode?"+params.Encode(), http.StatusSeeOther)
 		return
 	}
-	startTmpl.Execute(w, nil)
+	mutex.Lock()
+	user := sessionUser(r)
+	mutex.Unlock()
+	render(w, r, startTmpl, map[string]interface{}{
+		"Presets": presetOptions(),
+		"Layouts": listLayouts(),
+		"Colors":  playerColors,
+		"Labels":  colorLabels,
+		"User":    user,
+	})
 }
 
-// --- Modifie handler pour prendre en compte le mode ---
-func handler(w http.ResponseWriter, r *http.Request) {
-	mutex.Lock()
-	defer mutex.Unlock()
-	username := r.URL.Query().Get("username")
-	username2 := r.URL.Query().Get("username2")
-	difficulty := r.URL.Query().Get("difficulty")
-	mode := r.URL.Query().Get("mode")
-	skin := r.URL.Query().Get("skin") // Ajout du skin
-	gamemodeStr := r.URL.Query().Get("gamemode")
-	ailevelStr := r.URL.Query().Get("ailevel")
+// boardPreset décrit un plateau proposé à l'accueil.
+type boardPreset struct {
+	Rows, Cols         int
+	Prefill, Obstacles int // Jetons et obstacles placés au hasard au départ
+}
 
-	if mode != "inverse" {
-		mode = "normal"
-	}
+// Presets de plateau, par difficulté (game.presets dans la configuration).
+var boardPresets = config.Game.Presets
 
-	// Parse GameMode
-	var gameMode GameMode = ModeHumanVsHuman
-	if gamemodeStr == "ai" {
-		gameMode = ModeHumanVsAI
+// presetFor retourne le preset d'une difficulté, le plateau facile par défaut.
+func presetFor(difficulty string) boardPreset {
+	if p, ok := boardPresets[difficulty]; ok {
+		return p
 	}
+	return boardPresets["easy"]
+}
 
-	// Parse AILevel
-	var aiLevel AILevel = AIEasy
-	switch ailevelStr {
-	case "medium":
-		aiLevel = AIMedium
-	case "hard":
-		aiLevel = AIHard
-	default:
-		aiLevel = AIEasy
+// gameFromQuery crée une partie à partir des paramètres transmis par les formulaires d'accueil.
+func gameFromQuery(q url.Values) (*Game, error) {
+	settings, err := parseSettings(q)
+	if err != nil {
+		return nil, err
 	}
+	return settings.newGame()
+}
 
-	rows, cols, prefill := 6, 7, 0
-	switch difficulty {
-	case "easy":
-		rows, cols, prefill = 6, 7, 0
-	case "normal":
-		rows, cols, prefill = 7, 8, 0
-	case "hard":
-		rows, cols, prefill = 8, 10, 7
+// rematch crée la partie suivante avec les mêmes réglages et les mêmes joueurs : la manche
+// suivante d'une série en cours, sinon une nouvelle partie (ou une nouvelle série).
+func (g *Game) rematch() (*Game, error) {
+	q, _ := url.ParseQuery(g.Params)
+	next, err := gameFromQuery(q)
+	if err != nil {
+		return nil, err
 	}
-
-	// Normalise username2 pour le mode IA afin d'éviter une réinitialisation en boucle
-	normUsername2 := username2
-	if gameMode == ModeHumanVsAI && normUsername2 == "" {
-		normUsername2 = "IA"
+	if next.Players == g.Players {
+		for p := 1; p <= g.Players; p++ {
+			next.setPlayerName(p, g.playerName(p))
+		}
+		copy(next.Accounts, g.Accounts)
+		next.Guests = append([]string(nil), g.Guests...)
+	}
+	if g.Series != nil && !g.Series.Over() {
+		next.continueSeries(g.Series)
 	}
+	return next, nil
+}
+
+// --- Modifie handler pour prendre en compte le mode ---
+func handler(w http.ResponseWriter, r *http.Request) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	sess := currentSession(w, r)
+	q := r.URL.Query()
 
-	if game == nil || (username != "" && (game.Username != username || game.Username2 != normUsername2 || game.Difficulty != difficulty || game.Mode != mode || game.GameMode != gameMode || game.AILevel != aiLevel || game.Skin != skin)) {
-		game = NewGame(rows, cols, prefill, difficulty, username, normUsername2, mode, skin, gameMode, aiLevel)
+	game := games[q.Get("game")]
+	if game == nil {
+		if q.Get("game") != "" {
+			// Partie oubliée (terminée depuis longtemps ou abandonnée, voir cleanup.go), ou lien invalide
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		// Sans paramètres, on retourne à la partie en cours de la session
+		if q.Get("username") == "" && games[sess.GameID] != nil {
+			http.Redirect(w, r, "/connect4?game="+sess.GameID, http.StatusSeeOther)
+			return
+		}
+		// Un joueur connecté joue toujours sous le nom de son compte
+		if sess.User != "" {
+			q.Set("username", sess.User)
+		}
+		g, err := gameFromQuery(q)
+		if err != nil {
+			renderError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if sess.User != "" {
+			g.Accounts[0] = sess.User
+		}
+		games[g.ID] = g
+		sess.GameID = g.ID
+		http.Redirect(w, r, "/connect4?game="+g.ID, http.StatusSeeOther)
+		return
 	}
+	// Les joueurs d'une partie terminée suivent la revanche
+	if game.Next != "" && games[game.Next] != nil && r.Method != "POST" {
+		http.Redirect(w, r, "/connect4?game="+game.Next, http.StatusSeeOther)
+		return
+	}
+	sess.GameID = game.ID
+	// La pendule fait foi côté serveur : un drapeau tombé pendant l'absence du joueur est constaté ici
+	game.CheckFlag()
+	settleGame(game)
 
 	if r.Method == "POST" {
 		r.ParseForm()
+		if !checkCSRF(sess, r) {
+			renderError(w, r, http.StatusForbidden, "Formulaire expiré ou envoyé depuis un autre site : rechargez la page de la partie.")
+			return
+		}
+		// Les autres joueurs (navigateur, client terminal) suivent la partie en direct
+		defer notifyGame(game)
 		if r.FormValue("reset") == "1" {
-			game = nil
+			sess.GameID = ""
 			http.Redirect(w, r, "/", http.StatusSeeOther)
 			return
 		}
+		if r.FormValue("resign") == "1" {
+			if !game.takeSeat(sess, game.CurrentPlayer) {
+				renderError(w, r, http.StatusForbidden, "Ce n'est pas à vous de jouer.")
+				return
+			}
+			game.Resign()
+			settleGame(game)
+			http.Redirect(w, r, r.URL.String(), http.StatusSeeOther)
+			return
+		}
+		if seatStr := r.FormValue("claim"); seatStr != "" && sess.User != "" {
+			seat, _ := strconv.Atoi(seatStr)
+			game.claimSeat(seat, sess)
+			http.Redirect(w, r, r.URL.String(), http.StatusSeeOther)
+			return
+		}
+		if r.FormValue("rematch") == "1" && game.Tournament != "" {
+			// Pas de revanche libre en tournoi : la suite se joue depuis le tableau
+			http.Redirect(w, r, "/tournament?id="+game.Tournament, http.StatusSeeOther)
+			return
+		}
 		if r.FormValue("rematch") == "1" {
-			game = NewGame(rows, cols, prefill, difficulty, username, normUsername2, mode, skin, gameMode, aiLevel)
+			if game.Next != "" && games[game.Next] != nil {
+				sess.GameID = game.Next
+			} else {
+				g, err := game.rematch()
+				if err != nil {
+					renderError(w, r, http.StatusBadRequest, "Revanche impossible : "+err.Error())
+					return
+				}
+				games[g.ID] = g
+				game.Next = g.ID
+				sess.GameID = g.ID
+			}
+			http.Redirect(w, r, "/connect4?game="+sess.GameID, http.StatusSeeOther)
+			return
 		} else if colStr := r.FormValue("col"); colStr != "" {
-			col, err := strconv.Atoi(colStr)
-			if err == nil {
-				game.DropToken(col)
+			// "col" contient l'indice d'entrée : une colonne, ou une ligne en gravité latérale
+			player := game.CurrentPlayer
+			if !game.mayPlay(sess, player) {
+				renderError(w, r, http.StatusForbidden, "Ce n'est pas à vous de jouer.")
+				return
+			}
+			index, err := strconv.Atoi(colStr)
+			if err == nil && game.DropToken(index) {
+				game.takeSeat(sess, player)
+				movePlayed(r, game, player)
+				settleGame(game)
 
 				// En mode IA, ne joue PAS immédiatement ici.
-				// Le client déclenchera le coup IA après un délai (aiDelayMs) via /ai-move.
+				// Le client déclenchera le coup IA après un délai (tuning.AIDelayMs) via /ai-move.
 			}
 			// --- FIX: Redirect to avoid form resubmission on reload ---
 			http.Redirect(w, r, r.URL.String(), http.StatusSeeOther)
@@ -790,36 +478,16 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Prépare le message de fin si besoin
-	endMessage := ""
+	// Partie terminée : place au bilan (le plateau final y est affiché)
 	if game.GameOver {
-		if game.Winner == 1 {
-			name := game.Username1
-			if name == "" {
-				name = "Joueur 1"
-			}
-			endMessage = "🎉 Victoire de " + name + " !"
-		} else if game.Winner == 2 {
-			if game.GameMode == ModeHumanVsAI {
-				endMessage = "🤖 L'IA a gagné !"
-			} else {
-				name := game.Username2
-				if name == "" {
-					name = "Joueur 2"
-				}
-				endMessage = "🎉 Victoire de " + name + " !"
-			}
-		} else {
-			endMessage = "Match nul !"
-		}
+		http.Redirect(w, r, "/result?game="+game.ID, http.StatusSeeOther)
+		return
 	}
 
 	data := struct {
 		BoardHTML     template.HTML
 		CurrentPlayer int
-		Winner        int
-		GameOver      bool
-		Gravity       Gravity
+		Gravity       engine.Gravity
 		Username      string
 		Username1     string
 		Username2     string
@@ -828,14 +496,23 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		Cols          int
 		Mode          string
 		GameMode      GameMode
-		AILevel       AILevel
+		AILevel       engine.AILevel
 		Skin          string
-		EndMessage    string
+		Players       int
+		PlayerList    []playerInfo
+		Clocks        []clockInfo
+		GameID        string
+		User          string
+		FreeSeats     []int
+		Seat          int
+		Tournament    *Tournament
+		Series        *Series
+		Live          bool // La page se recharge quand un autre joueur fait évoluer la partie
+		Version       int
+		CSRF          string
 	}{
-		BoardHTML:     renderBoard(game),
+		BoardHTML:     renderBoard(game, sess.csrfToken()),
 		CurrentPlayer: game.CurrentPlayer,
-		Winner:        game.Winner,
-		GameOver:      game.GameOver,
 		Gravity:       game.Gravity,
 		Username:      game.Username,
 		Username1:     game.Username1,
@@ -847,45 +524,95 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		GameMode:      game.GameMode,
 		AILevel:       game.AILevel,
 		Skin:          game.Skin,
-		EndMessage:    endMessage,
-	}
-	pageTmpl.Execute(w, data)
+		Players:       game.Players,
+		PlayerList:    game.playerInfos(),
+		Clocks:        game.clockInfos(),
+		GameID:        game.ID,
+		User:          sess.User,
+		FreeSeats:     game.freeSeats(sess),
+		Seat:          game.seatOf(sess.User),
+		Tournament:    tournaments[game.Tournament],
+		Series:        game.Series,
+		Live:          game.GameMode == ModeHumanVsHuman,
+		Version:       game.Version,
+		CSRF:          sess.csrfToken(),
+	}
+	render(w, r, pageTmpl, data)
 }
 
 func main() {
-	// 1. Chargement des templates (comme sur ta photo)
+	// Sous-commandes (power4 recompute-ratings, ...) : pas de serveur
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+	cfg, err := loadConfig(os.Args[1:], true)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	applyConfig(cfg)
+	slog.SetDefault(newLogger(cfg.Server.Log))
+
+	// 1. Chargement des fichiers statiques et des templates (comme sur ta photo)
+	if err := loadAssets(); err != nil {
+		fatal("chargement des fichiers statiques", err)
+	}
 	if err := loadTemplates(); err != nil {
-		panic("Erreur chargement templates: " + err.Error())
+		fatal("chargement des templates", err)
+	}
+
+	// Base embarquée : comptes et sessions des joueurs connectés
+	if store, err = OpenStore(dataPath()); err != nil {
+		fatal("ouverture de la base", err)
 	}
+	loadSessions()
+	loadGames()
+	loadTournaments()
+	go sweepLoop()
 
 	// 2. Tes routes (comme sur ta photo)
 	http.HandleFunc("/", startHandler)
+	http.HandleFunc("/login", loginHandler)
+	http.HandleFunc("/register", registerHandler)
+	http.HandleFunc("/logout", logoutHandler)
+	http.HandleFunc("/profile", profileHandler)
+	http.HandleFunc("/leaderboard", leaderboardHandler)
+	http.HandleFunc("/stats", statsHandler)
+	http.HandleFunc("/matchmaking", matchmakingHandler)
+	http.HandleFunc("/tournaments", tournamentsHandler)
+	http.HandleFunc("/tournament", tournamentHandler)
 	http.HandleFunc("/mode", modeHandler)
 	http.HandleFunc("/ai-move", aiMoveHandler)
 	http.HandleFunc("/connect4", handler)
-
-	// 3. Gestion du CSS avec cache désactivé (comme sur ta photo)
-	http.HandleFunc("/style.css", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-		w.Header().Set("Pragma", "no-cache")
-		w.Header().Set("Expires", "0")
-		http.ServeFile(w, r, "style.css")
-	})
-
-	http.HandleFunc("/favicon.svg", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "image/svg+xml")
-		http.ServeFile(w, r, "favicon.svg")
-	})
-
-	// 5. GESTION DU PORT (Coolify)
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	http.HandleFunc("/result", resultHandler)
+	http.HandleFunc("/board.svg", boardImageHandler)
+	http.HandleFunc("/board.png", boardImageHandler)
+	http.HandleFunc("/game.gif", gameGIFHandler)
+	http.HandleFunc("/api/session", apiSessionHandler)
+	http.HandleFunc("/api/games", apiGamesHandler)
+	http.HandleFunc("/api/game", apiGameHandler)
+	http.HandleFunc("/api/events", gameEventsHandler)
+	http.HandleFunc("/metrics", metricsHandler)
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/readyz", readyzHandler)
+	http.HandleFunc("/admin", adminHandler)
+
+	// 3. Feuille de style, icône et scripts (embarqués, voir assets.go)
+	for _, name := range staticFiles {
+		http.HandleFunc("/"+name, assetHandler(name))
+	}
+
+	// 5. GESTION DU PORT (Coolify) : PORT, server.port ou -port
+	port := strconv.Itoa(cfg.Server.Port)
+	slog.Info("démarrage du serveur", "port", port)
+
+	// 6. Lancement du serveur, jusqu'à SIGTERM
+	if err := serve(":"+port, http.DefaultServeMux); err != nil {
+		fatal("serveur", err)
 	}
-	fmt.Println("Server starting on port " + port)
-
-	// 6. Lancement du serveur
-	http.ListenAndServe(":"+port, nil)
 }
 
 // aiMoveHandler effectue le coup de l'IA lorsqu'il est appelé (endpoint POST)
@@ -894,19 +621,45 @@ func aiMoveHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
 		return
 	}
+	// Une recherche difficile occupe le processeur : leur nombre simultané est limité
+	if !acquireAI(w, r) {
+		return
+	}
+	defer releaseAI()
 
 	mutex.Lock()
 	defer mutex.Unlock()
 
+	sess := findSession(r)
+	if sess == nil || !checkCSRF(sess, r) {
+		http.Error(w, "Jeton CSRF invalide", http.StatusForbidden)
+		return
+	}
+	// Seul le joueur qui suit la partie déclenche le coup de l'IA
+	game := games[sess.GameID]
+	if id := r.URL.Query().Get("game"); id != "" && id != sess.GameID {
+		http.Error(w, "Partie d'une autre session", http.StatusForbidden)
+		return
+	}
+	if game != nil && !game.takeSeat(sess, 1) {
+		http.Error(w, "Siège d'un autre joueur", http.StatusForbidden)
+		return
+	}
+	if game != nil {
+		game.CheckFlag()
+		settleGame(game)
+	}
 	if game == nil || game.GameMode != ModeHumanVsAI || game.GameOver || game.CurrentPlayer != 2 {
 		// Rien à faire
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	aiCol := game.aiMove()
-	if aiCol >= 0 {
-		game.DropToken(aiCol)
+	aiCol := aiDecision(r, game)
+	if aiCol >= 0 && game.DropToken(aiCol) {
+		movePlayed(r, game, 2)
+		settleGame(game)
+		notifyGame(game)
 	}
 
 	// OK
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// File d'attente des parties classées : les joueurs connectés y choisissent un plateau et une
+// gravité, et sont appariés avec l'adversaire de classement le plus proche. L'écart toléré
+// s'élargit avec l'attente, pour qu'un joueur isolé finisse toujours par trouver une partie.
+
+const (
+	matchWindow       = 100.0 // Écart de classement toléré au départ
+	matchWindowGrowth = 50.0  // Élargissement par tranche de matchWindowStep
+	matchWindowStep   = 10 * time.Second
+	matchWindowMax    = 800.0
+	matchRefresh      = 3                // Rafraîchissement de la page d'attente, en secondes
+	matchTimeout      = 30 * time.Second // Un joueur qui ne rafraîchit plus est retiré de la file
+)
+
+// matchRequest est un joueur en attente d'adversaire.
+type matchRequest struct {
+	User       string
+	Rating     float64
+	Difficulty string
+	Mode       string
+	Joined     time.Time
+	LastSeen   time.Time
+	GameID     string // Partie trouvée, en attente que le joueur la rejoigne
+}
+
+// File d'attente, protégée par mutex comme les parties.
+var matchQueue []*matchRequest
+
+// window retourne l'écart de classement accepté après le temps d'attente écoulé.
+func (m *matchRequest) window(now time.Time) float64 {
+	steps := float64(now.Sub(m.Joined) / matchWindowStep)
+	return math.Min(matchWindowMax, matchWindow+steps*matchWindowGrowth)
+}
+
+// queuedFor retourne la demande en cours d'un compte, ou nil.
+func queuedFor(user string) *matchRequest {
+	for _, m := range matchQueue {
+		if strings.EqualFold(m.User, user) {
+			return m
+		}
+	}
+	return nil
+}
+
+// leaveQueue retire une demande de la file.
+func leaveQueue(m *matchRequest) {
+	for i, other := range matchQueue {
+		if other == m {
+			matchQueue = append(matchQueue[:i], matchQueue[i+1:]...)
+			return
+		}
+	}
+}
+
+// pruneQueue retire les joueurs qui ont quitté la page d'attente sans se désinscrire.
+// Une partie trouvée entre-temps reste accessible depuis leur profil.
+func pruneQueue(now time.Time) {
+	kept := matchQueue[:0]
+	for _, m := range matchQueue {
+		if now.Sub(m.LastSeen) < matchTimeout {
+			kept = append(kept, m)
+		}
+	}
+	matchQueue = kept
+}
+
+// findOpponent cherche l'adversaire le plus proche au classement, avec les mêmes réglages et
+// dans l'écart accepté par les deux joueurs.
+func findOpponent(m *matchRequest, now time.Time) *matchRequest {
+	var best *matchRequest
+	for _, other := range matchQueue {
+		if other == m || other.GameID != "" || other.Difficulty != m.Difficulty || other.Mode != m.Mode {
+			continue
+		}
+		gap := math.Abs(other.Rating - m.Rating)
+		if gap > m.window(now) || gap > other.window(now) {
+			continue
+		}
+		if best == nil || gap < math.Abs(best.Rating-m.Rating) {
+			best = other
+		}
+	}
+	return best
+}
+
+// startMatch crée la partie classée entre deux joueurs ; le premier joueur est tiré au sort.
+func startMatch(a, b *matchRequest) error {
+	if rand.Intn(2) == 1 {
+		a, b = b, a
+	}
+	q := url.Values{
+		"username":   {a.User},
+		"username2":  {b.User},
+		"difficulty": {a.Difficulty},
+		"mode":       {a.Mode},
+		"gamemode":   {"human"},
+		"skin":       {"classic"},
+	}
+	g, err := gameFromQuery(q)
+	if err != nil {
+		return err
+	}
+	g.Accounts[0], g.Accounts[1] = a.User, b.User
+	games[g.ID] = g
+	a.GameID, b.GameID = g.ID, g.ID
+	return nil
+}
+
+// matchmakingHandler affiche la file d'attente et traite l'inscription ou le retrait.
+func matchmakingHandler(w http.ResponseWriter, r *http.Request) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	sess := findSession(r)
+	if sess == nil || sess.User == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	now := time.Now()
+	pruneQueue(now)
+	m := queuedFor(sess.User)
+
+	if r.Method == "POST" {
+		switch r.FormValue("action") {
+		case "join":
+			if m == nil {
+				difficulty, err := parseDifficulty(r.FormValue("difficulty"))
+				if err != nil {
+					renderError(w, r, http.StatusBadRequest, err.Error())
+					return
+				}
+				mode, err := parseMode(r.FormValue("mode"))
+				if err != nil {
+					renderError(w, r, http.StatusBadRequest, err.Error())
+					return
+				}
+				matchQueue = append(matchQueue, &matchRequest{
+					User:       sess.User,
+					Rating:     currentRating(sess.User),
+					Difficulty: difficulty,
+					Mode:       mode,
+					Joined:     now,
+					LastSeen:   now,
+				})
+			}
+		case "leave":
+			if m != nil {
+				leaveQueue(m)
+			}
+		}
+		http.Redirect(w, r, "/matchmaking", http.StatusSeeOther)
+		return
+	}
+
+	if m != nil {
+		m.LastSeen = now
+		if m.GameID == "" {
+			if opponent := findOpponent(m, now); opponent != nil {
+				if err := startMatch(m, opponent); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+		if m.GameID != "" {
+			leaveQueue(m)
+			sess.GameID = m.GameID
+			http.Redirect(w, r, "/connect4?game="+m.GameID, http.StatusSeeOther)
+			return
+		}
+	}
+
+	data := map[string]interface{}{
+		"User":    sess.User,
+		"Rating":  int(math.Round(currentRating(sess.User))),
+		"Waiting": len(matchQueue),
+		"Refresh": matchRefresh,
+		"Presets": presetOptions(),
+	}
+	if m != nil {
+		data["Queued"] = true
+		data["Difficulty"] = labelOr(difficultyLabels, m.Difficulty)
+		data["Mode"] = labelOr(modeLabels, m.Mode)
+		data["Window"] = int(m.window(now))
+		data["Elapsed"] = int(now.Sub(m.Joined).Seconds())
+	}
+	render(w, r, matchmakingTmpl, data)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Métriques du serveur au format texte de Prometheus, servies sur /metrics. Les compteurs
+// sont écrits à la main (pas de dépendance) : chaque série est identifiée par ses valeurs
+// d'étiquettes, dans l'ordre des noms d'étiquettes.
+
+// counter est un compteur à étiquettes.
+type counter struct {
+	mu     sync.Mutex
+	name   string
+	help   string
+	labels []string
+	values map[string]uint64
+}
+
+func newCounter(name, help string, labels ...string) *counter {
+	return &counter{name: name, help: help, labels: labels, values: map[string]uint64{}}
+}
+
+// inc incrémente la série des valeurs d'étiquettes données.
+func (c *counter) inc(values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[strings.Join(values, "\x00")]++
+}
+
+func (c *counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %d\n", c.name, labelSet(c.labels, strings.Split(key, "\x00")), c.values[key])
+	}
+}
+
+// histogram est un histogramme à une étiquette, en secondes.
+type histogram struct {
+	mu      sync.Mutex
+	name    string
+	help    string
+	label   string
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // Observations par seau (non cumulées), le dernier pour +Inf
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(name, help, label string, buckets ...float64) *histogram {
+	return &histogram{name: name, help: help, label: label, buckets: buckets, series: map[string]*histogramSeries{}}
+}
+
+// observe ajoute une durée à la série value.
+func (h *histogram) observe(value string, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[value]
+	if s == nil {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[value] = s
+	}
+	v := d.Seconds()
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, value := range sortedKeys(h.series) {
+		s := h.series[value]
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = strconv.FormatFloat(h.buckets[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelSet([]string{h.label, "le"}, []string{value, le}), cumulative)
+		}
+		labels := labelSet([]string{h.label}, []string{value})
+		fmt.Fprintf(w, "%s_sum%s %g\n%s_count%s %d\n", h.name, labels, s.sum, h.name, labels, s.count)
+	}
+}
+
+// gauge écrit une jauge sans étiquette.
+func gauge(w io.Writer, name, help string, value int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+}
+
+// labelSet formate les étiquettes d'une série : {nom="valeur",...}.
+func labelSet(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	gamesStarted = newCounter("power4_games_started_total", "Parties créées.", "game_mode", "mode", "difficulty")
+	gamesEnded   = newCounter("power4_games_finished_total", "Parties terminées, par issue.", "game_mode", "outcome")
+	movesPlayed  = newCounter("power4_moves_total", "Coups joués.", "player")
+	httpRequests = newCounter("power4_http_requests_total", "Requêtes HTTP servies, par code de réponse.", "code")
+	aiLatency    = newHistogram("power4_ai_move_duration_seconds", "Durée de recherche du coup de l'IA.", "level",
+		0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5)
+)
+
+// countGameStart compte une partie créée.
+func countGameStart(g *Game) {
+	gamesStarted.inc(g.GameMode.String(), g.Mode, g.Difficulty)
+}
+
+// countGameEnd compte une partie terminée : victoire, nul ou chute du drapeau.
+func countGameEnd(g *Game) {
+	outcome := "draw"
+	switch {
+	case g.Aborted:
+		outcome = "aborted"
+	case g.FlagFall != 0:
+		outcome = "timeout"
+	case g.Winner != 0:
+		outcome = "win"
+	}
+	gamesEnded.inc(g.GameMode.String(), outcome)
+}
+
+// countMove compte un coup du joueur player, humain ou IA.
+func countMove(g *Game, player int) {
+	who := "human"
+	if g.GameMode == ModeHumanVsAI && player == 2 {
+		who = "ai"
+	}
+	movesPlayed.inc(who)
+}
+
+// Une session compte comme active si son navigateur a fait une requête dans cette fenêtre.
+const activeSessionWindow = 15 * time.Minute
+
+// metricsHandler sert les métriques au format texte de Prometheus.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	mutex.Lock()
+	now := time.Now()
+	active := 0
+	for _, s := range sessions {
+		if s.Expires.After(now) && now.Sub(s.LastSeen) < activeSessionWindow {
+			active++
+		}
+	}
+	playing := 0
+	for _, g := range games {
+		if !g.GameOver {
+			playing++
+		}
+	}
+	mutex.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	gamesStarted.write(w)
+	gamesEnded.write(w)
+	movesPlayed.write(w)
+	aiLatency.write(w)
+	httpRequests.write(w)
+	gauge(w, "power4_active_sessions", "Sessions de navigateur ayant fait une requête dans les 15 dernières minutes.", active)
+	gauge(w, "power4_games_in_progress", "Parties en cours.", playing)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"power4/engine"
+)
+
+// Notation des parties : la suite des indices d'entrée joués, numérotés à partir de 1
+// (colonne en gravité verticale, ligne en gravité latérale). Sur un plateau de moins de
+// 10 colonnes et lignes, les coups s'écrivent à la suite ("4453") ; sinon ils sont séparés
+// par des virgules ("4,10,3").
+
+const maxNotationMoves = 400
+
+// formatMoves écrit une suite de coups en notation.
+func formatMoves(moves []int) string {
+	compact := true
+	for _, m := range moves {
+		if m+1 > 9 {
+			compact = false
+		}
+	}
+	parts := make([]string, len(moves))
+	for i, m := range moves {
+		parts[i] = strconv.Itoa(m + 1)
+	}
+	if compact {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseMoves lit une suite de coups en notation et retourne les indices (à partir de 0).
+func parseMoves(s string) ([]int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var parts []string
+	if strings.Contains(s, ",") {
+		parts = strings.Split(s, ",")
+	} else {
+		parts = strings.Split(s, "")
+	}
+	if len(parts) > maxNotationMoves {
+		return nil, errors.New("trop de coups")
+	}
+	moves := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("coup %d invalide : %q", i+1, p)
+		}
+		moves[i] = n - 1
+	}
+	return moves, nil
+}
+
+// replay joue une suite de coups avec les règles de DropToken.
+func (g *Game) replay(moves []int) error {
+	for i, m := range moves {
+		if !g.DropToken(m) {
+			return fmt.Errorf("coup %d (%d) impossible", i+1, m+1)
+		}
+	}
+	return nil
+}
+
+// positionFromQuery construit la position décrite par les paramètres moves (notation),
+// difficulty (taille du plateau, sans pré-remplissage aléatoire), mode, layout et skin.
+func positionFromQuery(q url.Values) (*Game, error) {
+	moves, err := parseMoves(q.Get("moves"))
+	if err != nil {
+		return nil, err
+	}
+	difficulty, err := parseDifficulty(q.Get("difficulty"))
+	if err != nil {
+		return nil, err
+	}
+	mode, err := parseMode(q.Get("mode"))
+	if err != nil {
+		return nil, err
+	}
+	preset := presetFor(difficulty)
+	g := NewGame(preset.Rows, preset.Cols, 0, 0, difficulty, "", "", mode, q.Get("skin"), ModeHumanVsHuman, engine.AIEasy)
+	if name := q.Get("layout"); name != "" {
+		layout, err := loadLayout(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := g.applyLayout(layout); err != nil {
+			return nil, err
+		}
+	}
+	if err := g.replay(moves); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"power4/engine"
+)
+
+// Couleurs de jeton proposées, dans l'ordre d'attribution par défaut.
+var playerColors = []string{"red", "yellow", "green", "blue", "purple", "orange"}
+
+// Libellés affichés dans les formulaires pour chaque couleur.
+var colorLabels = map[string]string{
+	"red":    "Rouge",
+	"yellow": "Jaune",
+	"green":  "Vert",
+	"blue":   "Bleu",
+	"purple": "Violet",
+	"orange": "Orange",
+}
+
+// multiPlayerBoard agrandit un preset pour laisser de la place aux joueurs supplémentaires :
+// une ligne et deux colonnes de plus par joueur au-delà de deux.
+func multiPlayerBoard(rows, cols, players int) (int, int) {
+	if players <= 2 {
+		return rows, cols
+	}
+	return rows + players - 2, cols + 2*(players-2)
+}
+
+// parseColors retourne une couleur valide et distincte pour chacun des joueurs.
+// Une couleur inconnue ou déjà prise est remplacée par la première couleur libre.
+func parseColors(requested []string, players int) []string {
+	colors := make([]string, players)
+	used := map[string]bool{}
+	for i := 0; i < players; i++ {
+		if i < len(requested) && colorLabels[requested[i]] != "" && !used[requested[i]] {
+			colors[i] = requested[i]
+			used[colors[i]] = true
+		}
+	}
+	for i := range colors {
+		if colors[i] != "" {
+			continue
+		}
+		for _, c := range playerColors {
+			if !used[c] {
+				colors[i] = c
+				used[c] = true
+				break
+			}
+		}
+	}
+	return colors
+}
+
+// NewMultiGame crée une partie libre à 3 ou 4 joueurs (chacun pour soi, sans IA).
+func NewMultiGame(rows, cols, prefill, obstacles int, difficulty string, usernames, colors []string, mode, skin string) *Game {
+	players := len(usernames)
+	g := NewGame(rows, cols, 0, 0, difficulty, usernames[0], usernames[1], mode, skin, ModeHumanVsHuman, engine.AIEasy)
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	g.Board = engine.RandomLayout(rows, cols, prefill, obstacles, players, g.Gravity, rng)
+	g.Players = players
+	g.Usernames = usernames
+	g.Colors = colors
+	g.Eliminated = make([]bool, players)
+	g.Accounts = make([]string, players)
+	g.CurrentPlayer = engine.StartingPlayer(g.Board, players)
+	return g
+}
+
+// colorOf retourne la couleur de jeton du joueur p.
+func (g *Game) colorOf(p int) string {
+	if p >= 1 && p <= len(g.Colors) {
+		return g.Colors[p-1]
+	}
+	return playerColors[(p-1)%len(playerColors)]
+}
+
+// setPlayerName change le nom du joueur p, en gardant Username1/Username2 à jour.
+func (g *Game) setPlayerName(p int, name string) {
+	if p < 1 || p > len(g.Usernames) {
+		return
+	}
+	g.Usernames[p-1] = name
+	switch p {
+	case 1:
+		g.Username = name
+		g.Username1 = name
+	case 2:
+		g.Username2 = name
+	}
+}
+
+// seatOf retourne le siège occupé par le compte name, ou 0.
+func (g *Game) seatOf(name string) int {
+	for i, account := range g.Accounts {
+		if account != "" && strings.EqualFold(account, name) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// mayPlay indique si la session peut jouer pour le joueur p : un siège lié à un compte n'est
+// joué que par ce compte, un siège invité par la session qui y a joué la première (par
+// quiconque a ouvert la partie tant que personne n'y a joué, ou si cette session a expiré).
+// Doit être appelé avec mutex verrouillé.
+func (g *Game) mayPlay(s *Session, p int) bool {
+	if account := g.Accounts[p-1]; account != "" {
+		return strings.EqualFold(account, s.User)
+	}
+	if p > len(g.Guests) || g.Guests[p-1] == "" || g.Guests[p-1] == s.ID {
+		return true
+	}
+	return sessions[g.Guests[p-1]] == nil
+}
+
+// takeSeat vérifie que la session peut jouer pour le joueur p et, pour un siège invité, le lui
+// réserve : les autres sessions ne peuvent plus y jouer. Une session peut tenir plusieurs
+// sièges (partie à plusieurs sur un même écran).
+func (g *Game) takeSeat(s *Session, p int) bool {
+	if !g.mayPlay(s, p) {
+		return false
+	}
+	if g.Accounts[p-1] == "" {
+		if len(g.Guests) < g.Players {
+			g.Guests = append(g.Guests, make([]string, g.Players-len(g.Guests))...)
+		}
+		g.Guests[p-1] = s.ID
+	}
+	return true
+}
+
+// moveGuestSeats transmet les sièges invités de la session from à la session to, qui la
+// remplace à la connexion ou à la déconnexion. Doit être appelé avec mutex verrouillé.
+func moveGuestSeats(from, to string) {
+	for _, g := range games {
+		for i, id := range g.Guests {
+			if id == from {
+				g.Guests[i] = to
+			}
+		}
+	}
+}
+
+// freeSeats liste les sièges humains que le compte de la session peut prendre : ni liés à un
+// compte, ni réservés par une autre session.
+func (g *Game) freeSeats(s *Session) []int {
+	var seats []int
+	for p := 1; p <= g.Players; p++ {
+		if g.GameMode == ModeHumanVsAI && p == 2 {
+			continue
+		}
+		if g.Accounts[p-1] == "" && g.mayPlay(s, p) {
+			seats = append(seats, p)
+		}
+	}
+	return seats
+}
+
+// claimSeat lie le siège p au compte de la session : le joueur prend le nom de son compte.
+// Un compte ne peut occuper qu'un siège par partie, et pas celui d'un autre invité.
+func (g *Game) claimSeat(p int, s *Session) bool {
+	user := s.User
+	if p < 1 || p > g.Players || g.Accounts[p-1] != "" || g.seatOf(user) != 0 || !g.mayPlay(s, p) {
+		return false
+	}
+	if g.GameMode == ModeHumanVsAI && p == 2 {
+		return false
+	}
+	g.Accounts[p-1] = user
+	g.setPlayerName(p, user)
+	return true
+}
+
+// playerName retourne le nom affiché du joueur p.
+func (g *Game) playerName(p int) string {
+	if p >= 1 && p <= len(g.Usernames) && g.Usernames[p-1] != "" {
+		return g.Usernames[p-1]
+	}
+	return "Joueur " + strconv.Itoa(p)
+}
+
+// playerInfo décrit un joueur pour l'en-tête de la page de jeu.
+type playerInfo struct {
+	Number     int
+	Name       string
+	Color      string
+	Eliminated bool
+	Current    bool
+}
+
+// playerInfos retourne la liste des joueurs dans l'ordre de jeu.
+func (g *Game) playerInfos() []playerInfo {
+	infos := make([]playerInfo, 0, g.Players)
+	for p := 1; p <= g.Players; p++ {
+		infos = append(infos, playerInfo{
+			Number:     p,
+			Name:       g.playerName(p),
+			Color:      g.colorOf(p),
+			Eliminated: g.Eliminated[p-1],
+			Current:    p == g.CurrentPlayer && !g.GameOver,
+		})
+	}
+	return infos
+}
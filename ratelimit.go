@@ -0,0 +1,244 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Protection contre les abus : limites de débit par adresse IP et par session sur les pages de
+// jeu et les routes coûteuses (images, connexion), limite de création de parties, nombre
+// maximal de recherches de l'IA et de rendus d'images simultanés, de flux d'événements ouverts
+// par adresse IP et taille maximale des corps de requête. Les limites se règlent dans la configuration (section limits, voir config.go) ;
+// 0 désactive une limite.
+
+// limiter est un seau à jetons par clé : chaque clé dispose de rate requêtes par minute, avec
+// une rafale de rate requêtes au plus.
+type limiter struct {
+	mu      sync.Mutex
+	rate    float64 // Jetons par seconde
+	burst   float64
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter crée un limiteur de perMinute requêtes par minute, ou nil si perMinute vaut 0.
+func newLimiter(perMinute int) *limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &limiter{rate: float64(perMinute) / 60, burst: float64(perMinute), buckets: map[string]*bucket{}}
+}
+
+// allow consomme un jeton de la clé key. Sans jeton disponible, retourne false et l'attente
+// avant le prochain jeton.
+func (l *limiter) allow(key string, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	b := l.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep oublie, une fois par minute, les clés dont le seau s'est rempli : elles ne limitent
+// plus rien et ne doivent pas s'accumuler en mémoire.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+var (
+	ipLimiter      *limiter
+	sessionLimiter *limiter
+	newGameLimiter *limiter
+	maxBodyBytes   int64
+	trustProxy     bool
+
+	// Places de recherche de l'IA : un jeton par recherche en cours
+	aiSlots chan struct{}
+	// Places de rendu d'image (PNG, SVG, GIF animé) : un jeton par rendu en cours
+	renderSlots chan struct{}
+
+	// Flux d'événements ouverts par adresse IP, au plus maxStreams (0 : sans limite)
+	streamsMu  sync.Mutex
+	streams    = map[string]int{}
+	maxStreams int
+)
+
+// configureLimits met en place les limites de la configuration.
+func configureLimits(c LimitsConfig, proxy bool) {
+	ipLimiter = newLimiter(c.RateIP)
+	sessionLimiter = newLimiter(c.RateSession)
+	newGameLimiter = newLimiter(c.RateNewGames)
+	maxBodyBytes = c.MaxBody
+	trustProxy = proxy
+	aiSlots = make(chan struct{}, c.AIConcurrency)
+	renderSlots = make(chan struct{}, c.RenderConcurrency)
+	maxStreams = c.MaxStreams
+}
+
+// clientIP retourne l'adresse du client : celle de la connexion, ou la dernière adresse de
+// X-Forwarded-For (ajoutée par le proxy) si server.trust_proxy est activé.
+func clientIP(r *http.Request) string {
+	if trustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			parts := strings.Split(fwd, ",")
+			return strings.TrimSpace(parts[len(parts)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// gameEndpoint indique si la requête vise une page soumise aux limites de débit : pages de
+// jeu, images rendues à la demande et formulaires de compte (le mot de passe est dérivé par
+// PBKDF2, volontairement lent).
+func gameEndpoint(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/connect4", "/ai-move", "/mode", "/matchmaking",
+		"/board.svg", "/board.png", "/game.gif", "/login", "/register":
+		return true
+	}
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// createsGame indique si la requête crée une partie.
+func createsGame(r *http.Request) bool {
+	return r.URL.Path == "/api/games" || (r.URL.Path == "/connect4" && r.URL.Query().Get("game") == "")
+}
+
+// tooManyRequests répond 429 avec l'attente conseillée dans Retry-After.
+func tooManyRequests(w http.ResponseWriter, r *http.Request, retry time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retry.Seconds())))))
+	const msg = "Trop de requêtes : patientez quelques secondes avant de réessayer."
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		apiError(w, http.StatusTooManyRequests, msg)
+		return
+	}
+	renderError(w, r, http.StatusTooManyRequests, msg)
+}
+
+// protect applique la taille maximale des corps de requête et les limites de débit.
+func protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maxBodyBytes > 0 {
+			if r.ContentLength > maxBodyBytes {
+				renderError(w, r, http.StatusRequestEntityTooLarge, "Requête trop volumineuse.")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		}
+		if gameEndpoint(r) {
+			now := time.Now()
+			ip := clientIP(r)
+			if ok, retry := ipLimiter.allow(ip, now); !ok {
+				tooManyRequests(w, r, retry)
+				return
+			}
+			if c, err := r.Cookie(sessionCookie); err == nil {
+				if ok, retry := sessionLimiter.allow(c.Value, now); !ok {
+					tooManyRequests(w, r, retry)
+					return
+				}
+			}
+			if createsGame(r) {
+				if ok, retry := newGameLimiter.allow(ip, now); !ok {
+					tooManyRequests(w, r, retry)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acquireAI réserve une place de recherche de l'IA, ou répond 429 si toutes sont prises.
+// Une recherche réservée se libère avec releaseAI. À appeler hors du mutex : les recherches en
+// attente du verrou occupent leur place.
+func acquireAI(w http.ResponseWriter, r *http.Request) bool {
+	select {
+	case aiSlots <- struct{}{}:
+		return true
+	default:
+		tooManyRequests(w, r, time.Second)
+		return false
+	}
+}
+
+// releaseAI libère une place de recherche de l'IA.
+func releaseAI() {
+	<-aiSlots
+}
+
+// acquireRender réserve une place de rendu d'image, ou répond 429 si toutes sont prises. Un
+// rendu réservé se libère avec releaseRender. À appeler hors du mutex, comme acquireAI.
+func acquireRender(w http.ResponseWriter, r *http.Request) bool {
+	select {
+	case renderSlots <- struct{}{}:
+		return true
+	default:
+		tooManyRequests(w, r, time.Second)
+		return false
+	}
+}
+
+// releaseRender libère une place de rendu d'image.
+func releaseRender() {
+	<-renderSlots
+}
+
+// acquireStream réserve un flux d'événements pour l'adresse du client, ou répond 429 s'il en a
+// déjà maxStreams ouverts. Un flux réservé se libère avec releaseStream et l'adresse retournée.
+func acquireStream(w http.ResponseWriter, r *http.Request) (string, bool) {
+	ip := clientIP(r)
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	if maxStreams > 0 && streams[ip] >= maxStreams {
+		tooManyRequests(w, r, 15*time.Second)
+		return "", false
+	}
+	streams[ip]++
+	return ip, true
+}
+
+// releaseStream libère un flux d'événements de l'adresse ip.
+func releaseStream(ip string) {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	if streams[ip]--; streams[ip] <= 0 {
+		delete(streams, ip)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiterBurstAndRefill(t *testing.T) {
+	l := newLimiter(60) // Un jeton par seconde, rafale de 60
+	now := time.Now()
+	for i := 0; i < 60; i++ {
+		if ok, _ := l.allow("ip", now); !ok {
+			t.Fatalf("requête %d refusée dans la rafale", i+1)
+		}
+	}
+	ok, retry := l.allow("ip", now)
+	if ok || retry <= 0 || retry > time.Second {
+		t.Fatalf("allow = %v, %v après la rafale ; attendu un refus d'au plus une seconde", ok, retry)
+	}
+	if ok, _ := l.allow("autre", now); !ok {
+		t.Fatal("une autre clé partage le seau")
+	}
+	if ok, _ := l.allow("ip", now.Add(time.Second)); !ok {
+		t.Fatal("pas de jeton après une seconde")
+	}
+	if ok, _ := newLimiter(0).allow("ip", now); !ok {
+		t.Fatal("une limite à 0 doit tout laisser passer")
+	}
+}
+
+func TestGameEndpointThrottlesEvents(t *testing.T) {
+	for _, path := range []string{"/api/events", "/api/game", "/board.png", "/login"} {
+		if !gameEndpoint(httptest.NewRequest("GET", path, nil)) {
+			t.Errorf("%s échappe aux limites de débit", path)
+		}
+	}
+}
+
+func TestAcquireStreamCapsPerClient(t *testing.T) {
+	configureLimits(LimitsConfig{AIConcurrency: 1, RenderConcurrency: 1, MaxStreams: 2}, false)
+	defer configureLimits(LimitsConfig{AIConcurrency: 1, RenderConcurrency: 1}, false)
+	request := func(addr string) *http.Request {
+		r := httptest.NewRequest("GET", "/api/events?game=x", nil)
+		r.RemoteAddr = addr
+		return r
+	}
+	var held []string
+	for i := 0; i < 2; i++ {
+		ip, ok := acquireStream(httptest.NewRecorder(), request("10.0.0.1:1234"))
+		if !ok {
+			t.Fatalf("flux %d refusé", i+1)
+		}
+		held = append(held, ip)
+	}
+	w := httptest.NewRecorder()
+	if _, ok := acquireStream(w, request("10.0.0.1:5678")); ok || w.Code != http.StatusTooManyRequests {
+		t.Fatalf("troisième flux accepté (code %d)", w.Code)
+	}
+	if _, ok := acquireStream(httptest.NewRecorder(), request("10.0.0.2:1234")); !ok {
+		t.Fatal("flux d'une autre adresse refusé")
+	}
+	releaseStream("10.0.0.2")
+	releaseStream(held[0])
+	if _, ok := acquireStream(httptest.NewRecorder(), request("10.0.0.1:1234")); !ok {
+		t.Fatal("flux refusé après la fermeture d'un autre")
+	}
+	releaseStream(held[1])
+	releaseStream("10.0.0.1")
+	if len(streams) != 0 {
+		t.Fatalf("compteurs restants : %v", streams)
+	}
+}
+
+func TestSweepClosesStreamsOfExpiredGames(t *testing.T) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	now := time.Now()
+	g := &Game{ID: "sweep-test", Started: now.Add(-2 * idleGameTTL)}
+	games[g.ID] = g
+	ch := watchGame(g.ID)
+	defer unwatchGame(g.ID, ch)
+	if _, evicted := sweepState(now); evicted != 1 || games[g.ID] != nil {
+		t.Fatal("partie abandonnée gardée parce qu'un flux la suit")
+	}
+	select {
+	case <-ch:
+	default:
+		t.Fatal("le flux de la partie oubliée n'est pas prévenu")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Classement Elo des parties entre humains.
+//
+// Seules les parties à deux joueurs dont les deux sièges sont liés à des comptes différents
+// sont classées : un nom d'invité peut être emprunté par n'importe qui. Les classements ne
+// sont jamais modifiés à la main : ils se déduisent entièrement de l'historique des
+// résultats, ce qui permet de tout recalculer après un changement de formule
+// (commande « power4 recompute-ratings »).
+
+const (
+	initialRating    = 1500.0
+	eloK             = 24.0 // Coefficient des joueurs établis
+	provisionalK     = 40.0 // Coefficient des premières parties, pour converger plus vite
+	provisionalGames = 20
+)
+
+// Rating est le classement d'un compte et son historique.
+type Rating struct {
+	Name    string        `json:"name"`
+	Value   float64       `json:"value"`
+	Games   int           `json:"games"`
+	History []RatingPoint `json:"history"`
+	Rank    int           `json:"-"` // Place au classement, renseignée par ratingRanking
+}
+
+// RatingPoint est l'évolution du classement après une partie.
+type RatingPoint struct {
+	GameID   string    `json:"game_id"`
+	Time     time.Time `json:"time"`
+	Opponent string    `json:"opponent"`
+	Before   float64   `json:"before"`
+	After    float64   `json:"after"`
+}
+
+// RatingChange est la variation de classement d'un joueur, affichée en fin de partie.
+type RatingChange struct {
+	Name   string `json:"name"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+}
+
+// Delta retourne la variation signée, formatée pour l'affichage (+12, -8, ±0).
+func (c RatingChange) Delta() string {
+	switch d := c.After - c.Before; {
+	case d > 0:
+		return "+" + strconv.Itoa(d)
+	case d < 0:
+		return strconv.Itoa(d)
+	default:
+		return "±0"
+	}
+}
+
+// Rounded retourne le classement arrondi.
+func (r *Rating) Rounded() int {
+	return int(math.Round(r.Value))
+}
+
+// expectedScore retourne le score attendu d'un joueur classé a face à un joueur classé b.
+func expectedScore(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// kFactor retourne le coefficient Elo d'un joueur selon son nombre de parties classées.
+func kFactor(r *Rating) float64 {
+	if r.Games < provisionalGames {
+		return provisionalK
+	}
+	return eloK
+}
+
+// isRated indique si une partie compte pour le classement.
+func isRated(rec *GameRecord) bool {
+	return !rec.Aborted && rec.GameMode == ModeHumanVsHuman && len(rec.Accounts) == 2 &&
+		rec.Accounts[0] != "" && rec.Accounts[1] != "" && !strings.EqualFold(rec.Accounts[0], rec.Accounts[1])
+}
+
+// ratingFor retourne le classement d'un compte, créé au classement initial si besoin.
+func ratingFor(ratings map[string]*Rating, name string) *Rating {
+	key := strings.ToLower(name)
+	if ratings[key] == nil {
+		ratings[key] = &Rating{Name: name, Value: initialRating}
+	}
+	return ratings[key]
+}
+
+// applyElo met à jour les classements après une partie et retourne les variations,
+// ou nil si la partie n'est pas classée.
+func applyElo(ratings map[string]*Rating, rec *GameRecord) []RatingChange {
+	if !isRated(rec) {
+		return nil
+	}
+	a, b := ratingFor(ratings, rec.Accounts[0]), ratingFor(ratings, rec.Accounts[1])
+	scoreA := 0.5
+	switch rec.Winner {
+	case 1:
+		scoreA = 1
+	case 2:
+		scoreA = 0
+	}
+	expectedA := expectedScore(a.Value, b.Value)
+	beforeA, beforeB := a.Value, b.Value
+	a.Value += kFactor(a) * (scoreA - expectedA)
+	b.Value += kFactor(b) * ((1 - scoreA) - (1 - expectedA))
+	a.Games++
+	b.Games++
+	a.History = append(a.History, RatingPoint{GameID: rec.ID, Time: rec.Ended, Opponent: b.Name, Before: beforeA, After: a.Value})
+	b.History = append(b.History, RatingPoint{GameID: rec.ID, Time: rec.Ended, Opponent: a.Name, Before: beforeB, After: b.Value})
+	return []RatingChange{
+		{Name: a.Name, Before: int(math.Round(beforeA)), After: a.Rounded()},
+		{Name: b.Name, Before: int(math.Round(beforeB)), After: b.Rounded()},
+	}
+}
+
+// recomputeRatings repart de zéro et rejoue tout l'historique des résultats.
+func recomputeRatings(d *storeData) {
+	d.Ratings = map[string]*Rating{}
+	for _, rec := range d.Results {
+		rec.RatingChanges = applyElo(d.Ratings, rec)
+	}
+}
+
+// Change retourne la variation arrondie due à la partie.
+func (p RatingPoint) Change() RatingChange {
+	return RatingChange{Name: p.Opponent, Before: int(math.Round(p.Before)), After: int(math.Round(p.After))}
+}
+
+// Recent retourne les n dernières évolutions du classement, de la plus récente à la plus ancienne.
+func (r *Rating) Recent(n int) []RatingPoint {
+	recent := make([]RatingPoint, 0, n)
+	for i := len(r.History) - 1; i >= 0 && len(recent) < n; i-- {
+		recent = append(recent, r.History[i])
+	}
+	return recent
+}
+
+// lookupRating retourne une copie du classement d'un compte, ou nil s'il n'a jamais joué de partie classée.
+func lookupRating(name string) *Rating {
+	var rating *Rating
+	store.View(func(d *storeData) {
+		if r := d.Ratings[strings.ToLower(name)]; r != nil {
+			copied := *r
+			copied.History = append([]RatingPoint(nil), r.History...)
+			rating = &copied
+		}
+	})
+	return rating
+}
+
+// currentRating retourne le classement d'un compte (classement initial s'il n'a jamais joué).
+func currentRating(name string) float64 {
+	value := initialRating
+	store.View(func(d *storeData) {
+		if r := d.Ratings[strings.ToLower(name)]; r != nil {
+			value = r.Value
+		}
+	})
+	return value
+}
+
+// ratingRanking retourne les comptes classés, du meilleur au moins bon.
+func ratingRanking() []*Rating {
+	var list []*Rating
+	store.View(func(d *storeData) {
+		for _, r := range d.Ratings {
+			copied := *r
+			list = append(list, &copied)
+		}
+	})
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Value != list[j].Value {
+			return list[i].Value > list[j].Value
+		}
+		return list[i].Name < list[j].Name
+	})
+	for i, r := range list {
+		r.Rank = i + 1
+	}
+	return list
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"power4/engine"
+)
+
+// Client terminal en ligne : la partie est hébergée par un serveur Power-4 et jouée via son
+// API JSON (api.go). Les coups de l'adversaire arrivent par le flux /api/events ; si le
+// serveur ou un proxy ne le permet pas, le client interroge /api/game à intervalle régulier.
+// Le client ouvre d'abord une session (/api/session), invitée ou connectée à un compte, dont il
+// garde le cookie et joint le jeton anti-CSRF à chaque coup.
+
+const (
+	// Intervalle d'interrogation du serveur lorsque le flux d'événements n'est pas disponible.
+	pollInterval = 2 * time.Second
+	// Tentatives de demande du coup de l'IA à un serveur occupé.
+	aiRetries = 3
+)
+
+// errNoStream signale un serveur qui ne sert pas de flux d'événements.
+var errNoStream = errors.New("flux d'événements indisponible")
+
+// remoteGame est la connexion du client terminal à une partie d'un serveur.
+type remoteGame struct {
+	base      string // Adresse du serveur, sans / final
+	id        string
+	seat      int
+	client    *http.Client // Garde le cookie de session
+	csrf      string       // Jeton anti-CSRF de la session
+	live      atomic.Bool  // Flux d'événements connecté
+	aiDelay   atomic.Int64 // Délai avant le coup de l'IA annoncé par le serveur, en ms
+	aiVersion int          // Version de la partie pour laquelle le coup de l'IA a été demandé
+	cancel    context.CancelFunc
+}
+
+// stateGame reconstruit une partie à partir de l'état servi par l'API, pour l'affichage.
+func stateGame(s gameState) *Game {
+	g := &Game{
+		Game: engine.Game{
+			Rows:          s.Rows,
+			Cols:          s.Cols,
+			Board:         s.Board,
+			Mode:          s.Mode,
+			CurrentPlayer: s.CurrentPlayer,
+			Moves:         s.Moves,
+			LastRow:       s.LastRow,
+			LastCol:       s.LastCol,
+			GameOver:      s.GameOver,
+			Winner:        s.Winner,
+			Players:       len(s.Players),
+		},
+		ID:      s.ID,
+		Version: s.Version,
+		Next:    s.Next,
+	}
+	for _, gr := range []engine.Gravity{engine.GravityDown, engine.GravityUp, engine.GravityLeft, engine.GravityRight} {
+		if gr.String() == s.Gravity {
+			g.Gravity = gr
+		}
+	}
+	if s.VsAI {
+		g.GameMode = ModeHumanVsAI
+		for _, l := range []engine.AILevel{engine.AIEasy, engine.AIMedium, engine.AIHard} {
+			if l.String() == s.AILevel {
+				g.AILevel = l
+			}
+		}
+	}
+	for _, p := range s.Players {
+		g.Usernames = append(g.Usernames, p.Name)
+		g.Colors = append(g.Colors, p.Color)
+		g.Eliminated = append(g.Eliminated, p.Eliminated)
+	}
+	return g
+}
+
+// do envoie une requête à l'API et décode l'état retourné.
+func (c *remoteGame) do(method, path string, params url.Values) (*Game, error) {
+	var resp *http.Response
+	var err error
+	if method == "POST" {
+		req, rerr := http.NewRequest("POST", c.base+path, strings.NewReader(params.Encode()))
+		if rerr != nil {
+			return nil, rerr
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-CSRF-Token", c.csrf)
+		resp, err = c.client.Do(req)
+	} else {
+		resp, err = c.client.Get(c.base + path + "?" + params.Encode())
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) != nil || e.Error == "" {
+			e.Error = resp.Status
+		}
+		return nil, errors.New(e.Error)
+	}
+	var s gameState
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, fmt.Errorf("réponse du serveur illisible : %w", err)
+	}
+	c.aiDelay.Store(int64(s.AIDelayMs))
+	return stateGame(s), nil
+}
+
+// openSession ouvre la session du client, connectée au compte user si le nom est donné, et la
+// rattache à la partie id.
+func (c *remoteGame) openSession(user, password, id string) error {
+	params := url.Values{"name": {user}, "password": {password}, "game": {id}}
+	resp, err := c.client.PostForm(c.base+"/api/session", params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var s struct {
+		apiSession
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return fmt.Errorf("réponse du serveur illisible : %w", err)
+	}
+	if resp.StatusCode >= 400 || s.CSRF == "" {
+		if s.Error == "" {
+			s.Error = resp.Status
+		}
+		return errors.New(s.Error)
+	}
+	if c.csrf != s.CSRF { // Le jeton ne change qu'à la connexion : le coup de l'IA le lit en parallèle
+		c.csrf = s.CSRF
+	}
+	return nil
+}
+
+// fetch lit l'état de la partie id.
+func (c *remoteGame) fetch(id string) (*Game, error) {
+	return c.do("GET", "/api/game", url.Values{"game": {id}})
+}
+
+// play joue à l'indice index pour le siège du client.
+func (c *remoteGame) play(index int) (*Game, error) {
+	params := url.Values{"col": {strconv.Itoa(index)}, "player": {strconv.Itoa(c.seat)}}
+	return c.do("POST", "/api/game?game="+url.QueryEscape(c.id), params)
+}
+
+// aiMove demande au serveur de jouer le coup de l'IA, comme le fait la page de jeu.
+func (c *remoteGame) aiMove(id string) (*Game, error) {
+	return c.do("POST", "/api/game?game="+url.QueryEscape(id), url.Values{"ai": {"1"}})
+}
+
+// channel décrit la façon dont le client suit la partie.
+func (c *remoteGame) channel() string {
+	if c.live.Load() {
+		return "en direct"
+	}
+	return "interrogation toutes les " + pollInterval.String()
+}
+
+// stream lit le flux d'événements de la partie id et transmet chaque état reçu, jusqu'à la
+// fin du flux ou l'annulation de ctx.
+func (c *remoteGame) stream(ctx context.Context, id string, updates chan<- *Game) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.base+"/api/events?game="+url.QueryEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	// Pas de délai global : le flux reste ouvert toute la partie
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return errNoStream
+	}
+	c.live.Store(true)
+	defer c.live.Store(false)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue // Commentaire de maintien de la connexion ou ligne vide
+		}
+		var s gameState
+		if json.Unmarshal([]byte(data), &s) != nil {
+			continue
+		}
+		c.aiDelay.Store(int64(s.AIDelayMs))
+		select {
+		case updates <- stateGame(s):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+// watch suit la partie id jusqu'à l'annulation de ctx : par le flux d'événements tant que le
+// serveur le permet, sinon en interrogeant l'API toutes les pollInterval.
+func (c *remoteGame) watch(ctx context.Context, id string, updates chan<- *Game) {
+	stream := true
+	for ctx.Err() == nil {
+		if stream && c.stream(ctx, id, updates) == errNoStream {
+			stream = false
+		}
+		if g, err := c.fetch(id); err == nil {
+			select {
+			case updates <- g:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// connectTUI prépare le client pour une partie du serveur : la partie id, ou une nouvelle
+// partie créée avec params dont l'adversaire recevra le lien. Avec user, le client joue sous
+// ce compte.
+func connectTUI(server, id string, seat int, user, password string, params url.Values) (*tui, error) {
+	jar, _ := cookiejar.New(nil)
+	c := &remoteGame{base: strings.TrimRight(server, "/"), client: &http.Client{Timeout: 10 * time.Second, Jar: jar}}
+	if err := c.openSession(user, password, id); err != nil {
+		return nil, err
+	}
+	var g *Game
+	var err error
+	if id == "" {
+		g, err = c.do("POST", "/api/games", params)
+	} else {
+		g, err = c.fetch(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.id, c.seat = g.ID, seat
+	if seat == 0 {
+		c.seat = 1
+		if id != "" && g.GameMode != ModeHumanVsAI {
+			c.seat = 2
+		}
+	}
+	if c.seat < 1 || c.seat > g.Players || (g.GameMode == ModeHumanVsAI && c.seat == 2) {
+		return nil, fmt.Errorf("siège %d indisponible dans cette partie", c.seat)
+	}
+	t := &tui{game: g, cursor: g.MoveCount() / 2, out: os.Stdout, remote: c, updates: make(chan *Game)}
+	if id == "" && g.GameMode != ModeHumanVsAI {
+		t.message = "Lien pour l'adversaire : " + c.base + "/connect4?game=" + g.ID
+	}
+	t.watch()
+	t.remoteAI()
+	return t, nil
+}
+
+// watch (re)lance le suivi de la partie courante.
+func (t *tui) watch() {
+	c := t.remote
+	if c.cancel != nil {
+		c.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	go c.watch(ctx, c.id, t.updates)
+}
+
+// update affiche un état reçu du serveur, sauf s'il est plus ancien que l'état affiché.
+func (t *tui) update(g *Game) {
+	if g.ID != t.game.ID || g.Version < t.game.Version {
+		return
+	}
+	if g.Next != "" && t.game.Next == "" {
+		t.message = "Revanche lancée : n pour la rejoindre"
+	}
+	t.game = g
+	t.clampCursor()
+	t.remoteAI()
+}
+
+// remoteAI demande le coup de l'IA quand c'est son tour dans une partie contre l'IA : c'est
+// le client du joueur humain qui le déclenche, comme la page de jeu.
+func (t *tui) remoteAI() {
+	c, g := t.remote, t.game
+	if !t.aiTurn() || c.aiVersion == g.Version {
+		return
+	}
+	c.aiVersion = g.Version
+	delay := time.Duration(c.aiDelay.Load()) * time.Millisecond
+	go func() {
+		time.Sleep(delay)
+		// Le serveur limite les recherches simultanées de l'IA : on réessaie un peu plus tard
+		for range aiRetries {
+			if next, err := c.aiMove(g.ID); err == nil {
+				t.updates <- next
+				return
+			}
+			time.Sleep(pollInterval)
+		}
+	}()
+}
+
+// playRemote joue à l'indice sélectionné sur le serveur.
+func (t *tui) playRemote() {
+	c := t.remote
+	if t.game.CurrentPlayer != c.seat {
+		t.message = "Ce n'est pas votre tour"
+		return
+	}
+	g, err := c.play(t.cursor)
+	if err != nil {
+		t.message = err.Error()
+		return
+	}
+	t.message = ""
+	t.update(g)
+}
+
+// follow rejoint la revanche lancée depuis une autre interface.
+func (t *tui) follow() {
+	next := t.game.Next
+	if next == "" {
+		t.message = "Pas de revanche pour l'instant : elle se lance depuis la page de la partie"
+		return
+	}
+	g, err := t.remote.fetch(next)
+	if err == nil {
+		// La session suit la revanche, pour pouvoir y demander le coup de l'IA
+		err = t.remote.openSession("", "", next)
+	}
+	if err != nil {
+		t.message = err.Error()
+		return
+	}
+	t.remote.id = g.ID
+	t.game, t.message = g, "Revanche"
+	t.clampCursor()
+	t.watch()
+	t.remoteAI()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Bilan d'une partie terminée : la page de jeu y redirige dès la fin de la partie. Le joueur
+// qui la consulte voit la page de victoire (win.html) ou de défaite (lose.html) avec le plateau
+// final, le nombre de coups, la durée, le score de la série, la revanche et le lien du replay.
+
+// publicURL est l'adresse du site dans les liens à partager (server.public_url), sans barre
+// finale ; vide, l'adresse de la requête sert.
+var publicURL string
+
+// absoluteURL retourne l'adresse complète de path (qui commence par /) pour un lien à copier
+// hors du site : l'adresse publique configurée, sinon le schéma et l'hôte de la requête (le
+// schéma annoncé par X-Forwarded-Proto derrière un proxy de confiance).
+func absoluteURL(r *http.Request, path string) string {
+	if publicURL != "" {
+		return publicURL + path
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); trustProxy && (proto == "http" || proto == "https") {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// resultView est le bilan affiché par win.html et lose.html.
+type resultView struct {
+	Username      string // Joueur à qui la page s'adresse
+	Draw          bool
+	Summary       string // Issue de la partie : vainqueur, nul, chute du drapeau
+	GameID        string
+	Moves         int
+	Notation      string
+	Duration      string
+	Series        *Series
+	Player1       string // Joueurs de la série, dans l'ordre du score
+	Player2       string
+	RatingChanges []RatingChange
+	RematchLabel  string // Bouton de revanche : mêmes réglages, mêmes joueurs, série poursuivie
+	ReplayURL     string
+	Tournament    *Tournament
+	CSRF          string
+}
+
+// endMessage décrit l'issue d'une partie terminée.
+func (g *Game) endMessage() string {
+	var msg string
+	switch {
+	case g.Aborted:
+		return "Partie close sans résultat par l'administration."
+	case g.Winner == 2 && g.GameMode == ModeHumanVsAI:
+		msg = "🤖 L'IA a gagné !"
+	case g.Winner != 0:
+		msg = "🎉 Victoire de " + g.playerName(g.Winner) + " !"
+	default:
+		msg = "Match nul !"
+	}
+	if g.FlagFall != 0 {
+		msg = "⏱️ Temps écoulé pour " + g.playerName(g.FlagFall) + " ! " + msg
+	}
+	return msg
+}
+
+// viewerSeat retourne le siège du joueur qui consulte la partie : celui de son compte, le
+// joueur humain face à l'IA, ou 0 pour une partie à plusieurs sur le même écran et pour un
+// spectateur.
+func (g *Game) viewerSeat(s *Session) int {
+	if seat := g.seatOf(s.User); seat != 0 {
+		return seat
+	}
+	if g.GameMode == ModeHumanVsAI && s.GameID == g.ID {
+		return 1
+	}
+	return 0
+}
+
+// formatDuration écrit une durée de partie : « 45 s », « 3 min 05 s » ou « 1 h 02 min ».
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%d s", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%d min %02d s", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%d h %02d min", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// resultHandler affiche le bilan de la partie ?game=ID.
+func resultHandler(w http.ResponseWriter, r *http.Request) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	sess := findSession(r)
+	g := games[r.URL.Query().Get("game")]
+	if g == nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	if !g.GameOver {
+		http.Redirect(w, r, "/connect4?game="+g.ID, http.StatusSeeOther)
+		return
+	}
+
+	ended := g.Ended
+	if ended.IsZero() {
+		ended = time.Now()
+	}
+	view := resultView{
+		Draw:          g.Winner == 0,
+		Summary:       g.endMessage(),
+		GameID:        g.ID,
+		Moves:         len(g.Moves),
+		Notation:      formatMoves(g.Moves),
+		Duration:      formatDuration(ended.Sub(g.Started)),
+		Series:        g.Series,
+		Player1:       g.playerName(1),
+		Player2:       g.playerName(2),
+		RatingChanges: g.RatingChanges,
+		RematchLabel:  g.rematchLabel(),
+		ReplayURL:     absoluteURL(r, "/game.gif?game="+g.ID),
+		Tournament:    tournaments[g.Tournament],
+	}
+	// Un spectateur sans session n'a pas de revanche à proposer : le bilan seul lui est montré
+	if sess != nil {
+		view.CSRF = sess.csrfToken()
+	} else {
+		sess = &Session{}
+	}
+
+	// Victoire pour le vainqueur (ou, sans siège connu, pour l'écran partagé et les
+	// spectateurs), défaite pour les autres joueurs ; un nul s'affiche comme une victoire
+	seat := g.viewerSeat(sess)
+	tmpl := winTmpl
+	switch {
+	case g.Winner == 0 && seat != 0:
+		view.Username = g.playerName(seat)
+	case g.Winner == 0:
+		view.Username = sess.User
+	case seat == 0 || seat == g.Winner:
+		view.Username = g.playerName(g.Winner)
+	default:
+		view.Username = g.playerName(seat)
+		tmpl = loseTmpl
+	}
+	render(w, r, tmpl, view)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Series est un match en plusieurs manches (au meilleur de 3, 5 ou 7) entre les deux joueurs
+// d'une partie. Toutes les manches partagent la même série ; le joueur qui commence change à
+// chaque manche. Un nul ne compte pour personne : la série continue jusqu'à ce qu'un joueur
+// atteigne le nombre de victoires nécessaire.
+type Series struct {
+	BestOf int
+	Score  [2]int // Manches gagnées par le joueur 1 et le joueur 2
+	Draws  int
+	Games  []string // Identifiants des manches, dans l'ordre
+	Winner int      // Joueur qui a remporté la série (0 tant qu'elle continue)
+}
+
+// parseSeries lit le format d'une série ("3", "5" ou "7") ; une chaîne vide ou "1" donne une partie unique.
+func parseSeries(s string) (int, error) {
+	switch s {
+	case "", "1":
+		return 0, nil
+	case "3", "5", "7":
+		return strconv.Atoi(s)
+	}
+	return 0, errors.New("format de série invalide : " + s)
+}
+
+// needed retourne le nombre de manches à gagner pour remporter la série.
+func (s *Series) needed() int {
+	return s.BestOf/2 + 1
+}
+
+// Over indique si la série est terminée.
+func (s *Series) Over() bool {
+	return s.Winner != 0
+}
+
+// Round retourne le numéro de la manche en cours (ou de la dernière manche jouée).
+func (s *Series) Round() int {
+	return len(s.Games)
+}
+
+// record compte le résultat d'une manche terminée.
+func (s *Series) record(g *Game) {
+	if s.Over() {
+		return
+	}
+	switch g.Winner {
+	case 1, 2:
+		s.Score[g.Winner-1]++
+		if s.Score[g.Winner-1] >= s.needed() {
+			s.Winner = g.Winner
+		}
+	default:
+		s.Draws++
+	}
+}
+
+// startSeries fait de la partie la première manche d'une nouvelle série.
+func (g *Game) startSeries(bestOf int) {
+	if bestOf == 0 || g.Players != 2 {
+		return
+	}
+	g.Series = &Series{BestOf: bestOf, Games: []string{g.ID}}
+}
+
+// continueSeries fait de la partie la manche suivante de la série : le joueur qui commence
+// alterne d'une manche à l'autre, le joueur 1 aux manches impaires (la position de départ
+// donne autant de jetons à chacun, voir checkSeriesStart).
+func (g *Game) continueSeries(s *Series) {
+	g.Series = s
+	s.Games = append(s.Games, g.ID)
+	g.CurrentPlayer = 2 - s.Round()%2
+}
+
+// checkSeriesStart refuse une série sur une position de départ où un joueur a un jeton
+// d'avance : il devrait alors toujours jouer en second et le premier joueur ne pourrait plus
+// alterner d'une manche à l'autre. Seule la position compte : un plateau aléatoire a toujours
+// le nombre de jetons de son preset (voir engine.RandomLayout), un plateau personnalisé celui
+// de son fichier.
+func checkSeriesStart(g *Game) error {
+	if tokenCount(g.Board, 1) != tokenCount(g.Board, 2) {
+		return errors.New("une série se joue sur une position de départ où les deux joueurs ont autant de jetons")
+	}
+	return nil
+}
+
+// tokenCount compte les jetons d'un joueur sur le plateau.
+func tokenCount(board [][]int, player int) int {
+	n := 0
+	for _, row := range board {
+		for _, v := range row {
+			if v == player {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// rematchLabel retourne le libellé du bouton qui enchaîne après la partie.
+func (g *Game) rematchLabel() string {
+	switch {
+	case g.Tournament != "":
+		return "Retour au tournoi"
+	case g.Series != nil && !g.Series.Over():
+		return "Manche suivante"
+	case g.Series != nil:
+		return "Nouvelle série"
+	}
+	return "Revanche"
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSeriesOnRandomPresetAlwaysStarts(t *testing.T) {
+	s, err := parseSettings(url.Values{"difficulty": {"hard"}, "series": {"3"}, "username": {"a"}, "username2": {"b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := presetFor("hard").Prefill / 2
+	for i := 0; i < 300; i++ {
+		g, err := s.newGame()
+		if err != nil {
+			t.Fatalf("série refusée sur le preset difficile : %v", err)
+		}
+		if n1, n2 := tokenCount(g.Board, 1), tokenCount(g.Board, 2); n1 != n2 || n1 != want && n1 != 0 {
+			t.Fatalf("position de départ avec %d et %d jetons, attendu %d chacun", n1, n2, want)
+		}
+		if g.CurrentPlayer != 1 {
+			t.Fatalf("première manche commencée par le joueur %d", g.CurrentPlayer)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Délais du serveur HTTP. Le flux /api/events lève le délai d'écriture pour sa connexion.
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 15 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 2 * time.Minute
+	shutdownTimeout   = 10 * time.Second
+)
+
+// contentSecurityPolicy n'autorise que les ressources du site : les scripts sont servis depuis
+// des fichiers (game.js, start.js), seuls les styles en ligne des pages restent permis.
+const contentSecurityPolicy = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; connect-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+// secureHeaders ajoute les en-têtes de sécurité à toutes les réponses.
+func secureHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", contentSecurityPolicy)
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "same-origin")
+		if r.TLS != nil {
+			h.Set("Strict-Transport-Security", "max-age=31536000")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// saveGames enregistre les parties en cours dans la base, pour les reprendre au redémarrage ;
+// les parties abandonnées (voir cleanup.go) ne le sont pas. Le temps déjà écoulé du joueur au
+// trait est décompté de sa pendule, arrêtée pendant la coupure. Doit être appelé avec mutex
+// verrouillé.
+func saveGames() (int, error) {
+	pending := map[string]*Game{}
+	now := time.Now()
+	for id, g := range games {
+		if g.GameOver || g.abandoned(now) {
+			continue
+		}
+		if g.Clock != nil {
+			g.Clock.Remaining[g.CurrentPlayer-1] = g.Remaining(g.CurrentPlayer)
+			g.Clock.TurnStart = time.Now()
+		}
+		pending[id] = g
+	}
+	return len(pending), store.Update(func(d *storeData) error {
+		d.Games = pending
+		return nil
+	})
+}
+
+// loadGames reprend les parties enregistrées à l'arrêt précédent, puis les retire de la base :
+// elles vivent de nouveau en mémoire jusqu'au prochain arrêt. Leurs pendules repartent
+// maintenant. À appeler avant loadTournaments, qui retrouve ainsi les parties de ses rencontres.
+func loadGames() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	now := time.Now()
+	var restored int
+	err := store.Update(func(d *storeData) error {
+		for id, g := range d.Games {
+			if g.Clock != nil {
+				g.Clock.TurnStart = now
+			}
+			games[id] = g
+		}
+		restored = len(d.Games)
+		d.Games = nil
+		return nil
+	})
+	if err != nil {
+		slog.Error("reprise des parties en cours", "err", err)
+	}
+	if restored > 0 {
+		slog.Info("parties en cours reprises", "games", restored)
+	}
+}
+
+// serve lance le serveur sur addr (en HTTPS si server.tls_cert et server.tls_key désignent un
+// certificat) jusqu'à SIGINT ou SIGTERM, puis l'arrête proprement : les requêtes en cours se
+// terminent, les flux d'événements sont fermés et les parties en cours enregistrées.
+func serve(addr string, handler http.Handler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Contexte de base des requêtes : annulé à l'arrêt pour fermer les flux d'événements,
+	// qui sinon retiendraient le serveur jusqu'au délai d'arrêt
+	base, cancel := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           logRequests(secureHeaders(protect(handler))),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		BaseContext:       func(net.Listener) context.Context { return base },
+	}
+	srv.RegisterOnShutdown(cancel)
+
+	cert, key := config.Server.TLSCert, config.Server.TLSKey
+	errs := make(chan error, 1)
+	go func() {
+		if cert != "" && key != "" {
+			errs <- srv.ListenAndServeTLS(cert, key)
+		} else {
+			errs <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	slog.Info("arrêt du serveur")
+	shuttingDown.Store(true)
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	err := srv.Shutdown(shutdownCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		srv.Close()
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	saved, saveErr := saveGames()
+	if saveErr != nil {
+		return saveErr
+	}
+	slog.Info("parties en cours enregistrées", "games", saved)
+	return err
+}
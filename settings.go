@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"power4/engine"
+)
+
+// Longueur maximale d'un nom de joueur (celle des champs du formulaire d'accueil).
+const maxNameLength = 16
+
+// Skins proposés à l'accueil.
+var skins = []string{"classic", "neon", "retro"}
+
+// Settings sont les réglages d'une nouvelle partie, lus et validés une seule fois à partir des
+// formulaires d'accueil (ou des paramètres d'une revanche). Un réglage absent prend sa valeur
+// par défaut ; une valeur inconnue est une erreur.
+type Settings struct {
+	Players     int
+	Usernames   []string // Un nom par joueur ("" pour « Joueur N »)
+	Colors      []string // Couleur demandée par joueur ("" pour la première couleur libre)
+	Difficulty  string
+	Mode        string
+	Skin        string
+	GameMode    GameMode
+	AILevel     engine.AILevel
+	Layout      string
+	TimeControl engine.TimeControl
+	BestOf      int // 0 pour une partie unique
+}
+
+// oneOf vérifie qu'une valeur fait partie des valeurs permises ; une valeur vide donne def.
+func oneOf(field, value, def string, allowed ...string) (string, error) {
+	if value == "" {
+		return def, nil
+	}
+	for _, a := range allowed {
+		if value == a {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("%s %q invalide", field, value)
+}
+
+// parseDifficulty valide une difficulté (taille du plateau) ; une valeur vide donne "easy".
+func parseDifficulty(value string) (string, error) {
+	return oneOf("difficulté", value, "easy", "easy", "normal", "hard")
+}
+
+// parseMode valide un mode de gravité ; une valeur vide donne "normal".
+func parseMode(value string) (string, error) {
+	return oneOf("mode", value, "normal", "normal", "inverse", "lateral", "rotating")
+}
+
+// parseName valide un nom de joueur.
+func parseName(p int, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if !utf8.ValidString(name) || utf8.RuneCountInString(name) > maxNameLength {
+		return "", fmt.Errorf("le nom du joueur %d doit faire au plus %d caractères", p, maxNameLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("le nom du joueur %d contient un caractère invalide", p)
+		}
+	}
+	return name, nil
+}
+
+// parseSettings lit les réglages d'une partie dans les paramètres d'un formulaire.
+func parseSettings(q url.Values) (Settings, error) {
+	var s Settings
+	var err error
+	if s.Difficulty, err = parseDifficulty(q.Get("difficulty")); err != nil {
+		return s, err
+	}
+	if s.Mode, err = parseMode(q.Get("mode")); err != nil {
+		return s, err
+	}
+	if s.Skin, err = oneOf("skin", q.Get("skin"), "classic", skins...); err != nil {
+		return s, err
+	}
+	gameMode, err := oneOf("mode de jeu", q.Get("gamemode"), "human", "human", "ai")
+	if err != nil {
+		return s, err
+	}
+	if gameMode == "ai" {
+		s.GameMode = ModeHumanVsAI
+		level, err := oneOf("niveau de l'IA", q.Get("ailevel"), "easy", "easy", "medium", "hard")
+		if err != nil {
+			return s, err
+		}
+		for _, l := range []engine.AILevel{engine.AIEasy, engine.AIMedium, engine.AIHard} {
+			if l.String() == level {
+				s.AILevel = l
+			}
+		}
+	}
+	if s.Layout = q.Get("layout"); s.Layout != "" && !layoutNameRe.MatchString(s.Layout) {
+		return s, fmt.Errorf("nom de plateau %q invalide", s.Layout)
+	}
+	if s.TimeControl, err = engine.ParseTimeControl(q.Get("time")); err != nil {
+		return s, err
+	}
+	if s.BestOf, err = parseSeries(q.Get("series")); err != nil {
+		return s, err
+	}
+
+	// Partie libre à 3 ou 4 joueurs : uniquement entre humains
+	s.Players = 2
+	if p := q.Get("players"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 2 || n > 4 {
+			return s, fmt.Errorf("nombre de joueurs %q invalide", p)
+		}
+		if n > 2 && s.GameMode == ModeHumanVsAI {
+			return s, errors.New("les parties à plus de deux joueurs se jouent uniquement entre humains")
+		}
+		if n > 2 && s.BestOf != 0 {
+			return s, errors.New("les séries se jouent à deux joueurs")
+		}
+		s.Players = n
+	}
+	for p := 1; p <= s.Players; p++ {
+		key := "username"
+		if p > 1 {
+			key += strconv.Itoa(p)
+		}
+		name, err := parseName(p, q.Get(key))
+		if err != nil {
+			return s, err
+		}
+		color := q.Get("color" + strconv.Itoa(p))
+		if color != "" && colorLabels[color] == "" {
+			return s, fmt.Errorf("couleur %q invalide", color)
+		}
+		s.Usernames = append(s.Usernames, name)
+		s.Colors = append(s.Colors, color)
+	}
+	return s, nil
+}
+
+// Values encode les réglages dans les paramètres lus par parseSettings.
+func (s Settings) Values() url.Values {
+	v := url.Values{}
+	for p := 1; p <= s.Players; p++ {
+		key := "username"
+		if p > 1 {
+			key += strconv.Itoa(p)
+		}
+		if name := s.Usernames[p-1]; name != "" {
+			v.Set(key, name)
+		}
+		if color := s.Colors[p-1]; color != "" {
+			v.Set("color"+strconv.Itoa(p), color)
+		}
+	}
+	if s.Players > 2 {
+		v.Set("players", strconv.Itoa(s.Players))
+	}
+	v.Set("difficulty", s.Difficulty)
+	v.Set("mode", s.Mode)
+	v.Set("skin", s.Skin)
+	v.Set("gamemode", s.GameMode.String())
+	if s.GameMode == ModeHumanVsAI {
+		v.Set("ailevel", s.AILevel.String())
+	}
+	if s.Layout != "" {
+		v.Set("layout", s.Layout)
+	}
+	if tc := s.TimeControl.String(); tc != "" {
+		v.Set("time", tc)
+	}
+	if s.BestOf != 0 {
+		v.Set("series", strconv.Itoa(s.BestOf))
+	}
+	return v
+}
+
+// newGame crée la partie décrite par les réglages.
+func (s Settings) newGame() (*Game, error) {
+	preset := presetFor(s.Difficulty)
+	rows, cols := multiPlayerBoard(preset.Rows, preset.Cols, s.Players)
+	colors := parseColors(s.Colors, s.Players)
+
+	var g *Game
+	if s.Players > 2 {
+		g = NewMultiGame(rows, cols, preset.Prefill, preset.Obstacles, s.Difficulty, append([]string(nil), s.Usernames...), colors, s.Mode, s.Skin)
+	} else {
+		g = NewGame(rows, cols, preset.Prefill, preset.Obstacles, s.Difficulty, s.Usernames[0], s.Usernames[1], s.Mode, s.Skin, s.GameMode, s.AILevel)
+		g.Colors = colors
+	}
+	g.StartClock(s.TimeControl)
+	// Plateau personnalisé chargé depuis layouts/<nom>.txt
+	if s.Layout != "" {
+		layout, err := loadLayout(s.Layout)
+		if err != nil {
+			return nil, errors.New("Plateau invalide : " + err.Error())
+		}
+		if err := g.applyLayout(layout); err != nil {
+			return nil, errors.New("Plateau invalide : " + err.Error())
+		}
+	}
+	if s.BestOf != 0 {
+		if err := checkSeriesStart(g); err != nil {
+			return nil, err
+		}
+	}
+	g.startSeries(s.BestOf)
+	g.Params = s.Values().Encode()
+	countGameStart(g)
+	return g, nil
+}
+
+// renderError affiche la page d'erreur avec le code status.
+func renderError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	render(w, r, errorTmpl, struct {
+		Status  int
+		Title   string
+		Message string
+	}{status, http.StatusText(status), msg})
+}
@@ -0,0 +1,64 @@
+// Page d'accueil : aperçu du skin et champs affichés selon le mode de jeu.
+document.addEventListener('DOMContentLoaded', function () {
+    const skinPreview = document.getElementById('skin-preview');
+    const body = document.body;
+    const skinCards = Array.from(document.querySelectorAll('.skin-card'));
+    const gamemodeSelect = document.getElementById('gamemode-select');
+    const aiLevelLabel = document.getElementById('ai-level-label');
+    const username2Label = document.getElementById('username2-label');
+    const username1Text = document.getElementById('username1-text');
+    const usernameInput = document.getElementById('username-input');
+    const playersSelect = document.getElementById('players-select');
+    const playersLabel = document.getElementById('players-label');
+    const seriesLabel = document.getElementById('series-label');
+    const seriesSelect = document.getElementById('series-select');
+    function applySkin(skin) {
+        skinPreview.className = 'skin-preview-board ' + skin;
+        body.className = 'skin-' + skin;
+        skinCards.forEach(c => c.classList.toggle('selected', c.dataset.skin === skin));
+    }
+    skinCards.forEach(card => {
+        card.addEventListener('click', () => {
+            const input = card.querySelector('input[type="radio"]');
+            input.checked = true;
+            applySkin(input.value);
+        });
+        const input = card.querySelector('input[type="radio"]');
+        input.addEventListener('change', () => applySkin(input.value));
+    });
+    // init with checked value
+    const checked = document.querySelector('.skin-card input[type="radio"]:checked');
+    if (checked) applySkin(checked.value);
+
+    function toggleByMode() {
+        const isAI = gamemodeSelect.value === 'ai';
+        aiLevelLabel.style.display = isAI ? 'flex' : 'none';
+        username2Label.style.display = isAI ? 'none' : 'flex';
+        // Les parties à 3 ou 4 joueurs se jouent uniquement entre humains
+        playersLabel.style.display = isAI ? 'none' : 'flex';
+        playersSelect.disabled = isAI;
+        const players = isAI ? 2 : parseInt(playersSelect.value, 10);
+        document.querySelectorAll('.extra-player').forEach(function (el) {
+            el.style.display = parseInt(el.dataset.player, 10) <= players ? 'flex' : 'none';
+        });
+        document.querySelectorAll('.color-select').forEach(function (el) {
+            const show = parseInt(el.dataset.player, 10) <= players;
+            el.style.display = show ? '' : 'none';
+            el.disabled = !show;
+        });
+        // Les séries se jouent à deux
+        seriesLabel.style.display = players === 2 ? 'flex' : 'none';
+        seriesSelect.disabled = players !== 2;
+        // Mettre à jour le libellé et le placeholder du joueur 1 selon le mode
+        if (isAI) {
+            username1Text.textContent = 'Nom du joueur :';
+            if (usernameInput.placeholder === 'Joueur 1') usernameInput.placeholder = 'Votre pseudo';
+        } else {
+            username1Text.textContent = 'Nom du joueur 1 :';
+            if (usernameInput.placeholder === 'Votre pseudo') usernameInput.placeholder = 'Joueur 1';
+        }
+    }
+    gamemodeSelect.addEventListener('change', toggleByMode);
+    playersSelect.addEventListener('change', toggleByMode);
+    toggleByMode();
+});
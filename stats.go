@@ -0,0 +1,320 @@
+package main
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"power4/engine"
+)
+
+// GameRecord est le résultat d'une partie terminée, tel qu'enregistré dans la base.
+type GameRecord struct {
+	ID            string               `json:"id"`
+	Players       []string             `json:"players"`  // Nom retenu pour les statistiques de chaque siège ("" si non comptabilisé)
+	Accounts      []string             `json:"accounts"` // Compte lié à chaque siège ("" pour un invité)
+	Winner        int                  `json:"winner"`   // 0 pour un match nul
+	GameMode      GameMode             `json:"game_mode"`
+	AILevel       engine.AILevel       `json:"ai_level"`
+	Difficulty    string               `json:"difficulty"`
+	Mode          string               `json:"mode"`
+	TurnCount     int                  `json:"turn_count"`
+	Moves         []int                `json:"moves"`
+	Eliminations  []engine.Elimination `json:"eliminations,omitempty"` // Abandons des parties à plusieurs, pour le replay
+	FlagFall      int                  `json:"flag_fall,omitempty"`
+	Aborted       bool                 `json:"aborted,omitempty"` // Close sans résultat par l\'administration
+	Started       time.Time            `json:"started"`
+	Ended         time.Time            `json:"ended"`
+	RatingChanges []RatingChange       `json:"rating_changes,omitempty"` // Variation des classements Elo (parties classées)
+	Initial       [][]int              `json:"initial,omitempty"`        // Plateau avant le premier coup
+	FirstPlayer   int                  `json:"first_player,omitempty"`
+	Colors        []string             `json:"colors,omitempty"`
+}
+
+// Libellés des presets et des modes pour les pages de statistiques. La taille des plateaux
+// des presets suit la configuration (applyConfig).
+var (
+	difficultyLabels = map[string]string{"easy": "Facile (6x7)", "normal": "Normal (7x8)", "hard": "Difficile (8x10)"}
+	modeLabels       = map[string]string{"normal": "Normal", "inverse": "Gravité inversée", "lateral": "Gravité latérale", "rotating": "Gravité rotative"}
+)
+
+// statsName retourne le nom sous lequel le siège p est comptabilisé : le compte s'il est lié,
+// sinon le nom d'invité. L'IA, les invités sans nom et les invités qui empruntent le nom
+// d'un compte existant ne sont pas comptabilisés.
+func (g *Game) statsName(p int) string {
+	if g.Accounts[p-1] != "" {
+		return g.Accounts[p-1]
+	}
+	if g.GameMode == ModeHumanVsAI && p == 2 {
+		return ""
+	}
+	name := strings.TrimSpace(g.Usernames[p-1])
+	if name == "" || lookupUser(name) != nil {
+		return ""
+	}
+	return name
+}
+
+// settleGame enregistre le résultat d'une partie la première fois qu'elle est vue terminée.
+// Doit être appelé avec mutex verrouillé après chaque action pouvant finir la partie.
+func settleGame(g *Game) {
+	if !g.GameOver || g.Recorded {
+		return
+	}
+	g.Recorded = true
+	g.Ended = time.Now()
+	countGameEnd(g)
+	rec := &GameRecord{
+		ID:           g.ID,
+		Players:      make([]string, g.Players),
+		Accounts:     append([]string(nil), g.Accounts...),
+		Winner:       g.Winner,
+		GameMode:     g.GameMode,
+		AILevel:      g.AILevel,
+		Difficulty:   g.Difficulty,
+		Mode:         g.Mode,
+		TurnCount:    g.TurnCount,
+		Moves:        append([]int(nil), g.Moves...),
+		Eliminations: append([]engine.Elimination(nil), g.Eliminations...),
+		FlagFall:     g.FlagFall,
+		Aborted:      g.Aborted,
+		Started:      g.Started,
+		Ended:        g.Ended,
+		Initial:      g.Initial,
+		FirstPlayer:  g.FirstPlayer,
+		Colors:       append([]string(nil), g.Colors...),
+	}
+	for p := 1; p <= g.Players; p++ {
+		rec.Players[p-1] = g.statsName(p)
+	}
+	err := store.Update(func(d *storeData) error {
+		d.Results = append(d.Results, rec)
+		rec.RatingChanges = applyElo(d.Ratings, rec)
+		return nil
+	})
+	if err != nil {
+		slog.Error("enregistrement du résultat", "game", g.ID, "err", err)
+	}
+	g.RatingChanges = rec.RatingChanges
+	if g.Aborted {
+		// Sans résultat, la manche ne compte pas et la rencontre de tournoi se rejoue
+		replayTournamentGame(g)
+		return
+	}
+	if g.Series != nil {
+		g.Series.record(g)
+	}
+	if g.Tournament != "" {
+		recordTournamentGame(g)
+	}
+}
+
+// Record compte des victoires, défaites et nuls.
+type Record struct {
+	Label  string
+	Wins   int
+	Losses int
+	Draws  int
+}
+
+// Games retourne le nombre de parties du bilan.
+func (r *Record) Games() int {
+	return r.Wins + r.Losses + r.Draws
+}
+
+// WinRate retourne le pourcentage de victoires, arrondi.
+func (r *Record) WinRate() int {
+	if r.Games() == 0 {
+		return 0
+	}
+	return int(math.Round(100 * float64(r.Wins) / float64(r.Games())))
+}
+
+func (r *Record) add(outcome int) {
+	switch {
+	case outcome > 0:
+		r.Wins++
+	case outcome < 0:
+		r.Losses++
+	default:
+		r.Draws++
+	}
+}
+
+// PlayerStats regroupe les statistiques d'un joueur.
+type PlayerStats struct {
+	Name          string
+	Total         Record
+	VsAI          map[engine.AILevel]*Record
+	ByDifficulty  map[string]*Record
+	ByMode        map[string]*Record
+	CurrentStreak int // > 0 : victoires consécutives, < 0 : défaites consécutives
+	BestStreak    int
+	Rank          int // Place au classement, renseignée par leaderboardHandler
+	totalTurns    int
+	LastPlayed    time.Time
+}
+
+// AverageLength retourne la durée moyenne d'une partie, en nombre de coups.
+func (s *PlayerStats) AverageLength() float64 {
+	if s.Total.Games() == 0 {
+		return 0
+	}
+	return math.Round(10*float64(s.totalTurns)/float64(s.Total.Games())) / 10
+}
+
+// sortedRecords retourne les bilans d'une table, triés par libellé.
+func sortedRecords[K comparable](m map[K]*Record) []*Record {
+	list := make([]*Record, 0, len(m))
+	for _, r := range m {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Label < list[j].Label })
+	return list
+}
+
+// LossStreak retourne le nombre de défaites consécutives en cours.
+func (s *PlayerStats) LossStreak() int {
+	return max(0, -s.CurrentStreak)
+}
+
+// statsSection est un tableau de la page de statistiques.
+type statsSection struct {
+	Title   string
+	Records []*Record
+}
+
+// Sections prépare les tableaux détaillés de la page de statistiques.
+func (s *PlayerStats) Sections() []statsSection {
+	return []statsSection{
+		{"Contre l'IA", sortedRecords(s.VsAI)},
+		{"Par plateau", sortedRecords(s.ByDifficulty)},
+		{"Par mode", sortedRecords(s.ByMode)},
+	}
+}
+
+// computeStats rejoue l'historique des résultats pour calculer les statistiques de chaque joueur.
+// Les clés sont les noms en minuscules.
+func computeStats(results []*GameRecord) map[string]*PlayerStats {
+	all := map[string]*PlayerStats{}
+	for _, rec := range results {
+		if rec.Aborted {
+			continue
+		}
+		for i, name := range rec.Players {
+			if name == "" {
+				continue
+			}
+			key := strings.ToLower(name)
+			s := all[key]
+			if s == nil {
+				s = &PlayerStats{
+					Name:         name,
+					VsAI:         map[engine.AILevel]*Record{},
+					ByDifficulty: map[string]*Record{},
+					ByMode:       map[string]*Record{},
+				}
+				all[key] = s
+			}
+			outcome := 0
+			if rec.Winner == i+1 {
+				outcome = 1
+			} else if rec.Winner != 0 {
+				outcome = -1
+			}
+			s.Total.add(outcome)
+			if rec.GameMode == ModeHumanVsAI {
+				recordFor(s.VsAI, rec.AILevel, "IA "+rec.AILevel.Label()).add(outcome)
+			}
+			recordFor(s.ByDifficulty, rec.Difficulty, labelOr(difficultyLabels, rec.Difficulty)).add(outcome)
+			recordFor(s.ByMode, rec.Mode, labelOr(modeLabels, rec.Mode)).add(outcome)
+			s.totalTurns += rec.TurnCount
+			s.LastPlayed = rec.Ended
+
+			// Série en cours : un nul interrompt toute série
+			switch {
+			case outcome > 0 && s.CurrentStreak > 0:
+				s.CurrentStreak++
+			case outcome > 0:
+				s.CurrentStreak = 1
+			case outcome < 0 && s.CurrentStreak < 0:
+				s.CurrentStreak--
+			case outcome < 0:
+				s.CurrentStreak = -1
+			default:
+				s.CurrentStreak = 0
+			}
+			s.BestStreak = max(s.BestStreak, s.CurrentStreak)
+		}
+	}
+	return all
+}
+
+func recordFor[K comparable](m map[K]*Record, key K, label string) *Record {
+	if m[key] == nil {
+		m[key] = &Record{Label: label}
+	}
+	return m[key]
+}
+
+func labelOr(labels map[string]string, key string) string {
+	if label := labels[key]; label != "" {
+		return label
+	}
+	if key == "" {
+		return "Par défaut"
+	}
+	return key
+}
+
+// loadStats calcule les statistiques à partir de la base.
+func loadStats() map[string]*PlayerStats {
+	var stats map[string]*PlayerStats
+	store.View(func(d *storeData) {
+		stats = computeStats(d.Results)
+	})
+	return stats
+}
+
+// leaderboardHandler affiche le classement général.
+func leaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	stats := loadStats()
+	ranking := make([]*PlayerStats, 0, len(stats))
+	for _, s := range stats {
+		ranking = append(ranking, s)
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		a, b := ranking[i], ranking[j]
+		if a.Total.Wins != b.Total.Wins {
+			return a.Total.Wins > b.Total.Wins
+		}
+		if a.Total.WinRate() != b.Total.WinRate() {
+			return a.Total.WinRate() > b.Total.WinRate()
+		}
+		return a.Name < b.Name
+	})
+	for i, s := range ranking {
+		s.Rank = i + 1
+	}
+	render(w, r, leaderboardTmpl, map[string]interface{}{
+		"Ranking": ranking,
+		"Ratings": ratingRanking(),
+	})
+}
+
+// statsHandler affiche les statistiques détaillées d'un joueur.
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("player")
+	s := loadStats()[strings.ToLower(name)]
+	if s == nil {
+		http.Error(w, "Aucune partie enregistrée pour ce joueur", http.StatusNotFound)
+		return
+	}
+	render(w, r, statsTmpl, map[string]interface{}{
+		"Stats":      s,
+		"HasAccount": lookupUser(s.Name) != nil,
+	})
+}
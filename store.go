@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store est la base de données embarquée du serveur : un simple fichier JSON local,
+// chargé au démarrage et réécrit de façon atomique à chaque modification.
+// Aucun service externe n'est nécessaire, le jeu fonctionne hors ligne.
+type Store struct {
+	path string
+	mu   sync.Mutex
+	data storeData
+}
+
+// storeData est le contenu persistant de la base.
+type storeData struct {
+	Users    map[string]*User    `json:"users"`    // Comptes, indexés par nom en minuscules
+	Sessions map[string]*Session `json:"sessions"` // Sessions des joueurs connectés
+	Results  []*GameRecord       `json:"results"`  // Parties terminées, dans l'ordre de fin
+	Ratings  map[string]*Rating  `json:"ratings"`  // Classements Elo, indexés par nom en minuscules
+	// Tournois, par identifiant
+	Tournaments map[string]*Tournament `json:"tournaments"`
+	// Parties en cours à l'arrêt du serveur, reprises au démarrage
+	Games map[string]*Game `json:"games,omitempty"`
+}
+
+// Base utilisée par les handlers ; ouverte dans main.
+var store *Store
+
+// OpenStore ouvre (ou crée) la base stockée dans le fichier path.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path}
+	raw, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &s.data); err != nil {
+			return nil, errors.New("base " + path + " illisible : " + err.Error())
+		}
+	}
+	if s.data.Users == nil {
+		s.data.Users = map[string]*User{}
+	}
+	if s.data.Sessions == nil {
+		s.data.Sessions = map[string]*Session{}
+	}
+	if s.data.Tournaments == nil {
+		s.data.Tournaments = map[string]*Tournament{}
+	}
+	if s.data.Ratings == nil {
+		// Base antérieure aux classements : on les déduit de l'historique
+		recomputeRatings(&s.data)
+	}
+	return s, nil
+}
+
+// View donne un accès en lecture au contenu de la base.
+func (s *Store) View(fn func(d *storeData)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.data)
+}
+
+// Update modifie la base puis l'enregistre sur disque. Si fn retourne une erreur,
+// rien n'est enregistré.
+func (s *Store) Update(fn func(d *storeData) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := fn(&s.data); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// save écrit la base dans un fichier temporaire puis le renomme, pour ne jamais
+// laisser un fichier à moitié écrit en cas d'arrêt brutal.
+func (s *Store) save() error {
+	raw, err := json.MarshalIndent(&s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Ping vérifie que la base peut être enregistrée : son dossier existe et accepte l'écriture.
+func (s *Store) Ping() error {
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".ping-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// newID retourne un identifiant aléatoire de n octets, en hexadécimal.
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,879 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tournois : l'organisateur donne la liste des joueurs, le format, le plateau et la gravité ;
+// le serveur génère les appariements, crée les parties, relève les résultats et tient le
+// classement. Les tournois sont enregistrés dans la base ; les parties en cours le sont à
+// l'arrêt du serveur (saveGames), et une partie perdue est recréée au redémarrage.
+
+// TournamentFormat est le format d'un tournoi.
+type TournamentFormat string
+
+const (
+	FormatRoundRobin TournamentFormat = "round-robin" // Toutes rondes
+	FormatSingle     TournamentFormat = "single"      // Élimination directe
+	FormatDouble     TournamentFormat = "double"      // Double élimination (sans finale « reset »)
+	FormatSwiss      TournamentFormat = "swiss"       // Système suisse
+)
+
+var formatLabels = map[TournamentFormat]string{
+	FormatRoundRobin: "Toutes rondes",
+	FormatSingle:     "Élimination directe",
+	FormatDouble:     "Double élimination",
+	FormatSwiss:      "Système suisse",
+}
+
+// Label retourne le nom du format pour l'affichage.
+func (f TournamentFormat) Label() string {
+	return formatLabels[f]
+}
+
+// Résultat d'une rencontre.
+const (
+	resultPending = 0
+	resultA       = 1
+	resultB       = 2
+	resultDraw    = 3
+)
+
+const (
+	minTournamentPlayers = 2
+	maxTournamentPlayers = 64
+	byeEntrant           = "*" // Adversaire fictif : le joueur exempté passe sans jouer
+)
+
+// Les noms de joueur suivent les règles des comptes, avec des noms d'une ou deux lettres en plus.
+var tournamentNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,16}$`)
+
+// Tournament est un tournoi et tout son déroulement.
+type Tournament struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	Format     TournamentFormat `json:"format"`
+	Players    []string         `json:"players"` // Dans l'ordre des têtes de série
+	Difficulty string           `json:"difficulty"`
+	Mode       string           `json:"mode"`
+	Organizer  string           `json:"organizer"`
+	Matches    []*Match         `json:"matches"`
+	Round      int              `json:"round"`  // Ronde en cours (toutes rondes et suisse)
+	Rounds     int              `json:"rounds"` // Nombre de rondes prévues (toutes rondes et suisse)
+	Champion   string           `json:"champion,omitempty"`
+	Finished   bool             `json:"finished"`
+	Created    time.Time        `json:"created"`
+}
+
+// Match est une rencontre entre deux joueurs. En élimination, les joueurs d'une rencontre sont
+// connus au fur et à mesure : ils proviennent des rencontres sources.
+type Match struct {
+	Round   int          `json:"round"`
+	Bracket string       `json:"bracket,omitempty"` // "winners", "losers" ou "final" en double élimination
+	A       string       `json:"a,omitempty"`
+	B       string       `json:"b,omitempty"`
+	SrcA    *matchSource `json:"src_a,omitempty"`
+	SrcB    *matchSource `json:"src_b,omitempty"`
+	Games   []string     `json:"games,omitempty"` // Parties jouées ; en élimination, un nul est rejoué côtés inversés
+	Result  int          `json:"result"`
+}
+
+// matchSource désigne le vainqueur (ou le perdant) d'une autre rencontre.
+type matchSource struct {
+	Match int  `json:"match"`
+	Loser bool `json:"loser,omitempty"`
+}
+
+// Tournois en cours et terminés, protégés par mutex comme les parties.
+var tournaments = map[string]*Tournament{}
+
+// newTournament valide les paramètres et génère les premières rencontres.
+func newTournament(name string, format TournamentFormat, players []string, difficulty, mode, organizer string) (*Tournament, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 64 {
+		return nil, errors.New("le nom du tournoi doit faire 1 à 64 caractères")
+	}
+	if formatLabels[format] == "" {
+		return nil, errors.New("format de tournoi inconnu")
+	}
+	if len(players) < minTournamentPlayers || len(players) > maxTournamentPlayers {
+		return nil, fmt.Errorf("il faut de %d à %d joueurs", minTournamentPlayers, maxTournamentPlayers)
+	}
+	seen := map[string]bool{}
+	for _, p := range players {
+		if !tournamentNameRe.MatchString(p) {
+			return nil, fmt.Errorf("nom de joueur %q invalide (1 à 16 lettres, chiffres, _ ou -)", p)
+		}
+		if strings.EqualFold(p, "IA") {
+			return nil, errors.New("l'IA ne participe pas aux tournois")
+		}
+		if seen[strings.ToLower(p)] {
+			return nil, fmt.Errorf("le joueur %s est inscrit deux fois", p)
+		}
+		seen[strings.ToLower(p)] = true
+	}
+	difficulty, err := parseDifficulty(difficulty)
+	if err != nil {
+		return nil, err
+	}
+	if mode, err = parseMode(mode); err != nil {
+		return nil, err
+	}
+	t := &Tournament{
+		ID:         newID(6),
+		Name:       name,
+		Format:     format,
+		Players:    players,
+		Difficulty: difficulty,
+		Mode:       mode,
+		Organizer:  organizer,
+		Created:    time.Now(),
+	}
+	switch format {
+	case FormatRoundRobin:
+		t.buildRoundRobin()
+	case FormatSwiss:
+		t.Rounds = swissRounds(len(players))
+		t.Round = 1
+		t.pairSwissRound()
+	case FormatSingle:
+		t.buildSingle()
+	case FormatDouble:
+		t.buildDouble()
+	}
+	t.advance()
+	return t, nil
+}
+
+// buildRoundRobin génère toutes les rondes par la méthode du tourniquet : le premier joueur
+// reste fixe et les autres tournent d'une place à chaque ronde.
+func (t *Tournament) buildRoundRobin() {
+	ring := append([]string(nil), t.Players...)
+	if len(ring)%2 == 1 {
+		ring = append(ring, byeEntrant)
+	}
+	n := len(ring)
+	t.Rounds, t.Round = n-1, 1
+	for round := 1; round < n; round++ {
+		for i := 0; i < n/2; i++ {
+			a, b := ring[i], ring[n-1-i]
+			// Alterner les côtés pour que personne ne commence toujours
+			if round%2 == 0 {
+				a, b = b, a
+			}
+			t.Matches = append(t.Matches, &Match{Round: round, A: a, B: b})
+		}
+		ring = append([]string{ring[0], ring[n-1]}, ring[1:n-1]...)
+	}
+}
+
+// swissRounds retourne le nombre de rondes d'un système suisse : de quoi départager un
+// vainqueur unique, sans dépasser le nombre de rondes d'un toutes rondes.
+func swissRounds(players int) int {
+	rounds := 0
+	for 1<<rounds < players {
+		rounds++
+	}
+	return max(1, min(rounds, players-1))
+}
+
+// pairSwissRound apparie la ronde en cours : les joueurs sont classés par points et chacun
+// rencontre le mieux classé possible parmi ceux qu'il n'a pas encore affrontés, en revenant sur
+// les appariements précédents si la fin du classement ne peut plus s'apparier sans revanche.
+// En nombre impair, le dernier classé qui n'a pas encore été exempté (et dont l'exemption
+// laisse un appariement possible) marque aussitôt le point de l'exemption.
+func (t *Tournament) pairSwissRound() {
+	var pool []string
+	for _, s := range t.Standings() {
+		pool = append(pool, s.Name)
+	}
+	bye := -1
+	var pairs []string
+	if len(pool)%2 == 1 {
+		for i := len(pool) - 1; i >= 0 && pairs == nil; i-- {
+			if t.played(pool[i], byeEntrant) {
+				continue
+			}
+			rest := append(append([]string(nil), pool[:i]...), pool[i+1:]...)
+			if pairs = t.pairWithoutRematch(rest); pairs != nil {
+				bye = i
+			}
+		}
+		if pairs == nil {
+			// Tout le monde a déjà été exempté ou aucun appariement n'évite les revanches
+			bye = len(pool) - 1
+		}
+		t.Matches = append(t.Matches, &Match{Round: t.Round, A: pool[bye], B: byeEntrant, Result: resultA})
+		pool = append(pool[:bye], pool[bye+1:]...)
+	}
+	if pairs == nil {
+		pairs = t.pairWithoutRematch(pool)
+	}
+	if pairs == nil {
+		// Plus assez d'adversaires inédits : les revanches sont inévitables
+		pairs = pool
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		t.Matches = append(t.Matches, &Match{Round: t.Round, A: pairs[i], B: pairs[i+1]})
+	}
+}
+
+// pairWithoutRematch apparie les joueurs de pool (en nombre pair, dans l'ordre du classement)
+// sans revanche, par retour sur trace : le premier joueur prend le premier adversaire inédit
+// qui laisse le reste appariable. Retourne les joueurs deux à deux, ou nil si c'est impossible.
+func (t *Tournament) pairWithoutRematch(pool []string) []string {
+	if len(pool) == 0 {
+		return []string{}
+	}
+	for j := 1; j < len(pool); j++ {
+		if t.played(pool[0], pool[j]) {
+			continue
+		}
+		rest := append(append([]string(nil), pool[1:j]...), pool[j+1:]...)
+		if pairs := t.pairWithoutRematch(rest); pairs != nil {
+			return append([]string{pool[0], pool[j]}, pairs...)
+		}
+	}
+	return nil
+}
+
+// played indique si deux joueurs se sont déjà rencontrés.
+func (t *Tournament) played(a, b string) bool {
+	for _, m := range t.Matches {
+		if (m.A == a && m.B == b) || (m.A == b && m.B == a) {
+			return true
+		}
+	}
+	return false
+}
+
+// seedOrder retourne l'ordre des têtes de série dans un tableau de taille size (puissance de 2),
+// de sorte que les meilleures têtes de série ne se croisent qu'à la fin : 1, 8, 4, 5, 2, 7, 3, 6.
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, s := range order {
+			next = append(next, s, 2*len(order)+1-s)
+		}
+		order = next
+	}
+	return order
+}
+
+// firstRound place les joueurs dans le premier tour d'un tableau à élimination, complété par
+// des exemptions, et retourne les indices de ses rencontres.
+func (t *Tournament) firstRound(bracket string) []int {
+	size := 1
+	for size < len(t.Players) {
+		size *= 2
+	}
+	order := seedOrder(size)
+	entrant := func(seed int) string {
+		if seed <= len(t.Players) {
+			return t.Players[seed-1]
+		}
+		return byeEntrant
+	}
+	var round []int
+	for i := 0; i < size; i += 2 {
+		round = append(round, len(t.Matches))
+		t.Matches = append(t.Matches, &Match{Round: 1, Bracket: bracket, A: entrant(order[i]), B: entrant(order[i+1])})
+	}
+	return round
+}
+
+// pairWinners ajoute un tour où se rencontrent deux à deux les vainqueurs du tour précédent.
+func (t *Tournament) pairWinners(previous []int, round int, bracket string) []int {
+	var next []int
+	for i := 0; i+1 < len(previous); i += 2 {
+		next = append(next, len(t.Matches))
+		t.Matches = append(t.Matches, &Match{
+			Round:   round,
+			Bracket: bracket,
+			SrcA:    &matchSource{Match: previous[i]},
+			SrcB:    &matchSource{Match: previous[i+1]},
+		})
+	}
+	return next
+}
+
+// buildSingle génère tout le tableau d'une élimination directe.
+func (t *Tournament) buildSingle() {
+	round := t.firstRound("")
+	for r := 2; len(round) > 1; r++ {
+		round = t.pairWinners(round, r, "")
+	}
+}
+
+// buildDouble génère le tableau principal, le tableau de repêchage et la grande finale.
+// Au tour pair du repêchage, les rescapés rencontrent les perdants du tableau principal,
+// pris dans l'ordre inverse pour retarder les revanches.
+func (t *Tournament) buildDouble() {
+	winners := [][]int{t.firstRound("winners")}
+	for r := 2; len(winners[len(winners)-1]) > 1; r++ {
+		winners = append(winners, t.pairWinners(winners[len(winners)-1], r, "winners"))
+	}
+	wbFinal := winners[len(winners)-1][0]
+	finalist := &matchSource{Match: wbFinal, Loser: true}
+
+	if len(winners) > 1 {
+		// Premier tour du repêchage : les perdants du premier tour entre eux
+		var losers []int
+		first := winners[0]
+		for i := 0; i+1 < len(first); i += 2 {
+			losers = append(losers, len(t.Matches))
+			t.Matches = append(t.Matches, &Match{
+				Round:   1,
+				Bracket: "losers",
+				SrcA:    &matchSource{Match: first[i], Loser: true},
+				SrcB:    &matchSource{Match: first[i+1], Loser: true},
+			})
+		}
+		round := 2
+		for j := 1; j < len(winners); j++ {
+			dropped := winners[j]
+			var next []int
+			for i, m := range losers {
+				next = append(next, len(t.Matches))
+				t.Matches = append(t.Matches, &Match{
+					Round:   round,
+					Bracket: "losers",
+					SrcA:    &matchSource{Match: m},
+					SrcB:    &matchSource{Match: dropped[len(dropped)-1-i], Loser: true},
+				})
+			}
+			losers = next
+			round++
+			if len(losers) > 1 {
+				losers = t.pairWinners(losers, round, "losers")
+				round++
+			}
+		}
+		finalist = &matchSource{Match: losers[0]}
+	}
+	t.Matches = append(t.Matches, &Match{
+		Round:   1,
+		Bracket: "final",
+		SrcA:    &matchSource{Match: wbFinal},
+		SrcB:    finalist,
+	})
+}
+
+// winner et loser retournent les joueurs d'une rencontre terminée.
+func (m *Match) winner() string {
+	if m.Result == resultB {
+		return m.B
+	}
+	return m.A
+}
+
+func (m *Match) loser() string {
+	if m.Result == resultB {
+		return m.A
+	}
+	return m.B
+}
+
+// fill renseigne un joueur d'une rencontre à partir de sa source, si elle est jouée.
+func (t *Tournament) fill(entrant *string, src *matchSource) bool {
+	if *entrant != "" || src == nil {
+		return false
+	}
+	from := t.Matches[src.Match]
+	if from.Result == resultPending {
+		return false
+	}
+	if src.Loser {
+		*entrant = from.loser()
+	} else {
+		*entrant = from.winner()
+	}
+	return true
+}
+
+// advance propage les résultats : place les qualifiés, règle les exemptions, passe à la ronde
+// suivante quand elle est complète et désigne le vainqueur à la fin.
+func (t *Tournament) advance() {
+	for changed := true; changed; {
+		changed = false
+		for _, m := range t.Matches {
+			if m.Result != resultPending {
+				continue
+			}
+			if t.fill(&m.A, m.SrcA) || t.fill(&m.B, m.SrcB) {
+				changed = true
+			}
+			if m.A == byeEntrant || m.B == byeEntrant {
+				if m.A == byeEntrant && m.B != "" && m.B != byeEntrant {
+					m.Result = resultB
+				} else if m.B == byeEntrant && m.A != "" {
+					m.Result = resultA
+				} else if m.A == byeEntrant && m.B == byeEntrant {
+					m.Result = resultA
+				}
+				changed = changed || m.Result != resultPending
+			}
+		}
+	}
+
+	switch t.Format {
+	case FormatRoundRobin, FormatSwiss:
+		for !t.Finished && t.roundComplete(t.Round) {
+			if t.Round == t.Rounds {
+				t.Finished = true
+				if standings := t.Standings(); len(standings) > 0 {
+					t.Champion = standings[0].Name
+				}
+				break
+			}
+			t.Round++
+			if t.Format == FormatSwiss {
+				t.pairSwissRound()
+			}
+		}
+	default:
+		final := t.Matches[len(t.Matches)-1]
+		if final.Result != resultPending {
+			t.Finished = true
+			t.Champion = final.winner()
+		}
+	}
+}
+
+// roundComplete indique si toutes les rencontres d'une ronde sont jouées.
+func (t *Tournament) roundComplete(round int) bool {
+	for _, m := range t.Matches {
+		if m.Round == round && m.Result == resultPending {
+			return false
+		}
+	}
+	return true
+}
+
+// ready indique si une rencontre peut se jouer maintenant.
+func (t *Tournament) ready(m *Match) bool {
+	if m.Result != resultPending || m.A == "" || m.B == "" || m.A == byeEntrant || m.B == byeEntrant {
+		return false
+	}
+	if t.Format == FormatRoundRobin || t.Format == FormatSwiss {
+		return m.Round == t.Round
+	}
+	return true
+}
+
+// startGames crée les parties des rencontres prêtes : rencontre pas encore commencée, nul à
+// rejouer en élimination, ou partie perdue lors d'un redémarrage du serveur.
+// Doit être appelé avec mutex verrouillé.
+func (t *Tournament) startGames() error {
+	for i, m := range t.Matches {
+		if !t.ready(m) {
+			continue
+		}
+		if n := len(m.Games); n > 0 {
+			if g := games[m.Games[n-1]]; g != nil && !g.GameOver {
+				continue
+			}
+		}
+		// Côtés inversés à chaque nouvelle partie de la rencontre
+		first, second := m.A, m.B
+		if len(m.Games)%2 == 1 {
+			first, second = second, first
+		}
+		g, err := gameFromQuery(url.Values{
+			"username":   {first},
+			"username2":  {second},
+			"difficulty": {t.Difficulty},
+			"mode":       {t.Mode},
+			"gamemode":   {"human"},
+			"skin":       {"classic"},
+		})
+		if err != nil {
+			return err
+		}
+		for p, name := range []string{first, second} {
+			if user := lookupUser(name); user != nil {
+				g.Accounts[p] = user.Name
+			}
+		}
+		g.Tournament, g.Match = t.ID, i
+		games[g.ID] = g
+		m.Games = append(m.Games, g.ID)
+	}
+	return nil
+}
+
+// recordTournamentGame relève le résultat d'une partie de tournoi, appelé par settleGame.
+// Doit être appelé avec mutex verrouillé.
+func recordTournamentGame(g *Game) {
+	t := tournaments[g.Tournament]
+	if t == nil || g.Match >= len(t.Matches) {
+		return
+	}
+	m := t.Matches[g.Match]
+	if m.Result != resultPending || len(m.Games) == 0 || m.Games[len(m.Games)-1] != g.ID {
+		return
+	}
+	swapped := len(m.Games)%2 == 0
+	switch {
+	case g.Winner == 0 && (t.Format == FormatRoundRobin || t.Format == FormatSwiss):
+		m.Result = resultDraw
+	case g.Winner == 0:
+		// Élimination : un nul se rejoue, créé par startGames ci-dessous
+	case (g.Winner == 1) != swapped:
+		m.Result = resultA
+	default:
+		m.Result = resultB
+	}
+	t.advance()
+	t.startGames()
+	saveTournament(t)
+}
+
+// saveTournament enregistre une copie du tournoi dans la base : la base est écrite sous son
+// propre verrou, sans le mutex des parties.
+func saveTournament(t *Tournament) error {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	saved := &Tournament{}
+	if err := json.Unmarshal(raw, saved); err != nil {
+		return err
+	}
+	return store.Update(func(d *storeData) error {
+		d.Tournaments[saved.ID] = saved
+		return nil
+	})
+}
+
+// loadTournaments restaure les tournois de la base et recrée les parties en attente.
+func loadTournaments() {
+	store.View(func(d *storeData) {
+		for id, t := range d.Tournaments {
+			raw, _ := json.Marshal(t)
+			loaded := &Tournament{}
+			if json.Unmarshal(raw, loaded) == nil {
+				tournaments[id] = loaded
+			}
+		}
+	})
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, t := range tournaments {
+		if !t.Finished {
+			t.startGames()
+		}
+	}
+}
+
+// --- Classement ---
+
+// Standing est la ligne d'un joueur au classement du tournoi.
+type Standing struct {
+	Rank            int
+	Name            string
+	Played          int
+	Wins            int
+	Draws           int
+	Losses          int
+	Points          float64
+	Buchholz        float64 // Somme des points des adversaires
+	SonnebornBerger float64 // Points des adversaires battus, plus la moitié de ceux des adversaires tenus en échec
+	Out             bool    // Éliminé (formats à élimination)
+	exit            int     // Tour de l'élimination, pour départager les éliminés
+}
+
+// Standings calcule le classement. En toutes rondes et en suisse : points (victoire 1, nul ½,
+// exemption suisse 1), puis Sonneborn-Berger ou Buchholz selon le format. En élimination :
+// les joueurs encore en lice, puis les éliminés du plus tardif au plus précoce.
+func (t *Tournament) Standings() []*Standing {
+	byName := map[string]*Standing{}
+	list := make([]*Standing, 0, len(t.Players))
+	for _, p := range t.Players {
+		s := &Standing{Name: p}
+		byName[p] = s
+		list = append(list, s)
+	}
+	losses := map[string]int{}
+	for _, m := range t.Matches {
+		if m.Result == resultPending {
+			continue
+		}
+		a, b := byName[m.A], byName[m.B]
+		if a == nil || b == nil {
+			// Exemption : un point en suisse, rien ailleurs
+			if real := byName[m.winner()]; real != nil && t.Format == FormatSwiss {
+				real.Played++
+				real.Wins++
+				real.Points++
+			}
+			continue
+		}
+		a.Played++
+		b.Played++
+		switch m.Result {
+		case resultDraw:
+			a.Draws++
+			b.Draws++
+			a.Points += 0.5
+			b.Points += 0.5
+		default:
+			w, l := byName[m.winner()], byName[m.loser()]
+			w.Wins++
+			w.Points++
+			l.Losses++
+			losses[l.Name]++
+			switch {
+			case t.Format == FormatSingle:
+				l.Out, l.exit = true, m.Round
+			case t.Format == FormatDouble && m.Bracket == "final":
+				l.Out, l.exit = true, len(t.Matches)
+			case t.Format == FormatDouble && losses[l.Name] == 2:
+				l.Out, l.exit = true, m.Round
+			}
+		}
+	}
+
+	if t.Format == FormatSingle || t.Format == FormatDouble {
+		sort.SliceStable(list, func(i, j int) bool {
+			a, b := list[i], list[j]
+			if a.Out != b.Out {
+				return !a.Out
+			}
+			if a.exit != b.exit {
+				return a.exit > b.exit
+			}
+			return a.Wins > b.Wins
+		})
+	} else {
+		for _, m := range t.Matches {
+			a, b := byName[m.A], byName[m.B]
+			if m.Result == resultPending || a == nil || b == nil {
+				continue
+			}
+			a.Buchholz += b.Points
+			b.Buchholz += a.Points
+			switch m.Result {
+			case resultA:
+				a.SonnebornBerger += b.Points
+			case resultB:
+				b.SonnebornBerger += a.Points
+			default:
+				a.SonnebornBerger += b.Points / 2
+				b.SonnebornBerger += a.Points / 2
+			}
+		}
+		primary := func(s *Standing) float64 { return s.SonnebornBerger }
+		secondary := func(s *Standing) float64 { return s.Buchholz }
+		if t.Format == FormatSwiss {
+			primary, secondary = secondary, primary
+		}
+		sort.SliceStable(list, func(i, j int) bool {
+			a, b := list[i], list[j]
+			if a.Points != b.Points {
+				return a.Points > b.Points
+			}
+			if primary(a) != primary(b) {
+				return primary(a) > primary(b)
+			}
+			if secondary(a) != secondary(b) {
+				return secondary(a) > secondary(b)
+			}
+			return a.Wins > b.Wins
+		})
+	}
+	for i, s := range list {
+		s.Rank = i + 1
+	}
+	return list
+}
+
+// --- Affichage du tableau ---
+
+// matchView décrit une rencontre pour la page du tournoi.
+type matchView struct {
+	A, B    string
+	Winner  string
+	Status  string
+	GameID  string
+	Live    bool
+	Replays int
+}
+
+// roundView regroupe les rencontres d'un tour.
+type roundView struct {
+	Title   string
+	Matches []matchView
+}
+
+// roundTitle nomme un tour selon le format.
+func (t *Tournament) roundTitle(m *Match, lastRound int) string {
+	switch {
+	case m.Bracket == "final":
+		return "Grande finale"
+	case m.Bracket == "losers":
+		return fmt.Sprintf("Repêchage – tour %d", m.Round)
+	case t.Format == FormatRoundRobin || t.Format == FormatSwiss:
+		return fmt.Sprintf("Ronde %d", m.Round)
+	}
+	prefix := ""
+	if m.Bracket == "winners" {
+		prefix = "Tableau principal – "
+	}
+	switch lastRound - m.Round {
+	case 0:
+		return prefix + "Finale"
+	case 1:
+		return prefix + "Demi-finales"
+	case 2:
+		return prefix + "Quarts de finale"
+	}
+	return fmt.Sprintf("%sTour %d", prefix, m.Round)
+}
+
+// Bracket retourne les tours du tournoi dans l'ordre, pour l'affichage.
+// Doit être appelé avec mutex verrouillé.
+func (t *Tournament) Bracket() []roundView {
+	lastRound := 0
+	for _, m := range t.Matches {
+		if m.Bracket != "losers" && m.Bracket != "final" {
+			lastRound = max(lastRound, m.Round)
+		}
+	}
+	var rounds []roundView
+	index := map[string]int{}
+	for _, m := range t.Matches {
+		if m.A == byeEntrant && m.B == byeEntrant {
+			continue
+		}
+		title := t.roundTitle(m, lastRound)
+		if _, ok := index[title]; !ok {
+			index[title] = len(rounds)
+			rounds = append(rounds, roundView{Title: title})
+		}
+		v := matchView{A: entrantLabel(m.A), B: entrantLabel(m.B), Replays: max(0, len(m.Games)-1)}
+		switch {
+		case m.Result == resultDraw:
+			v.Status = "Nul"
+		case m.Result != resultPending:
+			v.Winner = m.winner()
+			v.Status = "Victoire de " + m.winner()
+			if m.A == byeEntrant || m.B == byeEntrant {
+				v.Status = "Exempt"
+			}
+		case m.A == "" || m.B == "":
+			v.Status = "En attente"
+		case t.ready(m):
+			v.Status = "En cours"
+			v.Live = true
+		default:
+			v.Status = "À venir"
+		}
+		if n := len(m.Games); n > 0 {
+			v.GameID = m.Games[n-1]
+		}
+		rounds[index[title]].Matches = append(rounds[index[title]].Matches, v)
+	}
+	return rounds
+}
+
+// entrantLabel affiche un joueur d'une rencontre, qualifié ou non.
+func entrantLabel(name string) string {
+	switch name {
+	case "":
+		return "?"
+	case byeEntrant:
+		return "exempt"
+	}
+	return name
+}
+
+// --- Handlers des tournois ---
+
+// tournamentsHandler liste les tournois et crée un tournoi (joueurs connectés uniquement).
+func tournamentsHandler(w http.ResponseWriter, r *http.Request) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	user := sessionUser(r)
+	data := map[string]interface{}{
+		"User":    user,
+		"Formats": formatLabels,
+		"Presets": presetOptions(),
+	}
+	if r.Method == "POST" {
+		if user == "" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		var players []string
+		for _, line := range strings.Split(r.FormValue("players"), "\n") {
+			if name := strings.TrimSpace(line); name != "" {
+				players = append(players, name)
+			}
+		}
+		t, err := newTournament(r.FormValue("name"), TournamentFormat(r.FormValue("format")), players,
+			r.FormValue("difficulty"), r.FormValue("mode"), user)
+		if err == nil {
+			err = t.startGames()
+		}
+		if err == nil {
+			err = saveTournament(t)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			data["Error"] = err.Error()
+			data["Name"] = r.FormValue("name")
+			data["PlayerList"] = r.FormValue("players")
+		} else {
+			tournaments[t.ID] = t
+			http.Redirect(w, r, "/tournament?id="+t.ID, http.StatusSeeOther)
+			return
+		}
+	}
+	list := make([]*Tournament, 0, len(tournaments))
+	for _, t := range tournaments {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	data["Tournaments"] = list
+	render(w, r, tournamentsTmpl, data)
+}
+
+// tournamentHandler affiche le tableau et le classement d'un tournoi, rafraîchis en direct.
+func tournamentHandler(w http.ResponseWriter, r *http.Request) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	t := tournaments[r.URL.Query().Get("id")]
+	if t == nil {
+		http.Error(w, "Tournoi introuvable", http.StatusNotFound)
+		return
+	}
+	// Parties terminées sans avoir été revues (pendule) et parties perdues au redémarrage
+	for _, m := range t.Matches {
+		if n := len(m.Games); n > 0 && m.Result == resultPending {
+			if g := games[m.Games[n-1]]; g != nil {
+				g.CheckFlag()
+				settleGame(g)
+			}
+		}
+	}
+	if !t.Finished {
+		t.startGames()
+	}
+	render(w, r, tournamentTmpl, map[string]interface{}{
+		"T":          t,
+		"Difficulty": labelOr(difficultyLabels, t.Difficulty),
+		"Mode":       labelOr(modeLabels, t.Mode),
+		"Rounds":     t.Bracket(),
+		"Standings":  t.Standings(),
+	})
+}
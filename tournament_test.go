@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// playSwiss joue un tournoi suisse jusqu'au bout, en tirant au sort le résultat de chaque
+// rencontre, et vérifie à chaque ronde qu'aucune exemption ne reste à jouer.
+func playSwiss(t *testing.T, players int, rng *rand.Rand) *Tournament {
+	t.Helper()
+	var names []string
+	for i := 0; i < players; i++ {
+		names = append(names, fmt.Sprintf("j%d", i+1))
+	}
+	tour, err := newTournament("Suisse", FormatSwiss, names, "easy", "normal", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for !tour.Finished {
+		for _, m := range tour.Matches {
+			if m.Round != tour.Round {
+				continue
+			}
+			if m.A == byeEntrant || m.B == byeEntrant {
+				if m.Result == resultPending {
+					t.Fatalf("%d joueurs, ronde %d : exemption de %s non réglée", players, m.Round, m.A)
+				}
+				if tour.ready(m) {
+					t.Fatalf("%d joueurs, ronde %d : exemption prête à être jouée", players, m.Round)
+				}
+				continue
+			}
+			if m.Result == resultPending {
+				m.Result = []int{resultA, resultB, resultDraw}[rng.Intn(3)]
+			}
+		}
+		round := tour.Round
+		tour.advance()
+		if !tour.Finished && tour.Round == round {
+			t.Fatalf("%d joueurs : la ronde %d ne se termine pas", players, round)
+		}
+	}
+	return tour
+}
+
+func TestSwissOddPlayersSettlesByes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, players := range []int{3, 5, 7, 9} {
+		tour := playSwiss(t, players, rng)
+		byes := map[string]int{}
+		for _, m := range tour.Matches {
+			if m.B == byeEntrant {
+				byes[m.A]++
+			}
+		}
+		if len(byes) != tour.Rounds {
+			t.Errorf("%d joueurs : %d exemptions pour %d rondes", players, len(byes), tour.Rounds)
+		}
+		for name, n := range byes {
+			if n > 1 {
+				t.Errorf("%d joueurs : %s exempté %d fois", players, name, n)
+			}
+		}
+		// L'exemption compte comme une victoire
+		for _, s := range tour.Standings() {
+			if s.Played != tour.Rounds {
+				t.Errorf("%d joueurs : %s a %d rencontres, attendu %d", players, s.Name, s.Played, tour.Rounds)
+			}
+		}
+	}
+}
+
+func TestSwissAvoidsRematches(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		players := 4 + rng.Intn(13)
+		tour := playSwiss(t, players, rng)
+		met := map[[2]string]int{}
+		for _, m := range tour.Matches {
+			a, b := m.A, m.B
+			if a > b {
+				a, b = b, a
+			}
+			met[[2]string{a, b}]++
+		}
+		for pair, n := range met {
+			if n > 1 {
+				t.Errorf("seed %d, %d joueurs : %s et %s se rencontrent %d fois", seed, players, pair[0], pair[1], n)
+			}
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"power4/engine"
+)
+
+// Client terminal : une partie locale jouée avec le moteur du serveur (DropToken, gravité,
+// IA), affichée en couleurs ANSI. Le terminal passe en mode brut via stty pour lire les
+// flèches ; si stty n'est pas disponible, les commandes se tapent suivies d'Entrée.
+
+// Codes de couleur ANSI des jetons.
+var ansiColors = map[string]string{
+	"red":    "1;31",
+	"yellow": "1;33",
+	"green":  "1;32",
+	"blue":   "1;34",
+	"purple": "1;35",
+	"orange": "1;38;5;208",
+}
+
+// Touches reconnues par le client terminal.
+type tuiKey int
+
+const (
+	keyNone tuiKey = iota
+	keyPrev
+	keyNext
+	keyDrop
+	keyUndo
+	keyNew
+	keyQuit
+	keyDigit // sélectionne l'indice choisi
+	keyPlay  // joue à l'indice choisi
+)
+
+// tui est l'état du client : la partie, l'indice d'entrée sélectionné et le dernier message.
+type tui struct {
+	game    *Game
+	cursor  int
+	message string
+	create  func() (*Game, error)
+	out     io.Writer
+	raw     bool        // Terminal en mode brut : une touche à la fois
+	remote  *remoteGame // Partie hébergée par un serveur (nil pour une partie locale)
+	updates chan *Game  // États reçus du serveur
+}
+
+// stty lance stty sur le terminal courant et retourne sa sortie.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// rawTerminal passe le terminal en mode brut et retourne la fonction qui le restaure.
+func rawTerminal() (func(), error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(state) }, nil
+}
+
+// keyPress est une touche lue, avec l'indice choisi pour keyDigit et keyPlay.
+type keyPress struct {
+	key   tuiKey
+	index int
+}
+
+// readKey lit une touche : flèches (séquences ESC [ A..D), chiffres, Entrée ou espace, lettres de commande.
+func readKey(r *bufio.Reader) (keyPress, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return keyPress{key: keyQuit}, err
+	}
+	switch b {
+	case 27:
+		if next, _ := r.ReadByte(); next != '[' && next != 'O' {
+			return keyPress{}, nil
+		}
+		switch arrow, _ := r.ReadByte(); arrow {
+		case 'D', 'A':
+			return keyPress{key: keyPrev}, nil
+		case 'C', 'B':
+			return keyPress{key: keyNext}, nil
+		}
+	case '\r', '\n', ' ':
+		return keyPress{key: keyDrop}, nil
+	case '0':
+		return keyPress{keyDigit, 9}, nil
+	case 3, 4: // Ctrl-C et Ctrl-D en mode brut
+		return keyPress{key: keyQuit}, nil
+	}
+	if b >= '1' && b <= '9' {
+		return keyPress{keyDigit, int(b - '1')}, nil
+	}
+	return letterKey(b), nil
+}
+
+// letterKey traduit les lettres de commande.
+func letterKey(b byte) keyPress {
+	switch b {
+	case 'h', 'k':
+		return keyPress{key: keyPrev}
+	case 'l', 'j':
+		return keyPress{key: keyNext}
+	case 'u', 'U':
+		return keyPress{key: keyUndo}
+	case 'n', 'N':
+		return keyPress{key: keyNew}
+	case 'q', 'Q':
+		return keyPress{key: keyQuit}
+	}
+	return keyPress{}
+}
+
+// readLine lit une commande tapée puis validée par Entrée, lorsque le terminal n'est pas en
+// mode brut : un numéro de file joue dans cette file, une ligne vide joue à l'indice
+// sélectionné, une lettre est une commande.
+func readLine(r *bufio.Reader) (keyPress, error) {
+	s, err := r.ReadString('\n')
+	if err != nil && s == "" {
+		return keyPress{key: keyQuit}, err
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return keyPress{key: keyDrop}, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return keyPress{keyPlay, n - 1}, nil
+	}
+	return letterKey(s[0]), nil
+}
+
+// takeBack retourne la partie rejouée sans son dernier coup.
+func (g *Game) takeBack() (*Game, error) {
+	if len(g.Moves) == 0 {
+		return nil, errors.New("aucun coup à annuler")
+	}
+	u := g.replayStart()
+	u.Difficulty, u.Layout, u.Skin = g.Difficulty, g.Layout, g.Skin
+	u.GameMode, u.AILevel = g.GameMode, g.AILevel
+	for p := 1; p <= g.Players; p++ {
+		u.setPlayerName(p, g.Usernames[p-1])
+	}
+	if err := u.replay(g.Moves[:len(g.Moves)-1]); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// undo annule le dernier coup ; contre l'IA, revient au dernier coup du joueur humain.
+func (t *tui) undo() {
+	if t.remote != nil {
+		t.message = "Pas d'annulation dans une partie en ligne"
+		return
+	}
+	g := t.game
+	for {
+		prev, err := g.takeBack()
+		if err != nil {
+			t.message = "Rien à annuler"
+			return
+		}
+		g = prev
+		if g.GameMode != ModeHumanVsAI || g.CurrentPlayer == 1 || len(g.Moves) == 0 {
+			break
+		}
+	}
+	t.game = g
+	t.message = "Coup annulé"
+	t.clampCursor()
+}
+
+// clampCursor garde le curseur sur un indice d'entrée existant (il change avec la gravité).
+func (t *tui) clampCursor() {
+	t.cursor = min(max(t.cursor, 0), t.game.MoveCount()-1)
+}
+
+// aiTurn indique si c'est à l'IA de jouer.
+func (t *tui) aiTurn() bool {
+	g := t.game
+	return g.GameMode == ModeHumanVsAI && !g.GameOver && g.CurrentPlayer == 2
+}
+
+// token retourne le jeton coloré du joueur p.
+func (t *tui) token(p int, mark string) string {
+	return "\x1b[" + ansiColors[t.game.colorOf(p)] + "m" + mark + "\x1b[0m"
+}
+
+// draw affiche le plateau, le curseur à l'entrée des jetons et la ligne d'état.
+func (t *tui) draw() {
+	g := t.game
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\x1b[K\r\n")
+	}
+	b.WriteString("\x1b[H")
+	line("Puissance 4 · mode %s · gravité %s", g.Mode, g.Gravity.Arrow())
+	if c := t.remote; c != nil {
+		line("Partie %s sur %s · vous jouez %s %s · %s", g.ID, c.base, t.token(c.seat, "●"), g.playerName(c.seat), c.channel())
+	} else {
+		line("")
+	}
+
+	winning := map[[2]int]bool{}
+	for _, pos := range g.WinningPositions() {
+		winning[pos] = true
+	}
+	cursor := func(on bool, mark string) string {
+		if on && !g.GameOver {
+			return "\x1b[1m" + mark + "\x1b[0m"
+		}
+		return " "
+	}
+	columns := func(mark string) {
+		var s strings.Builder
+		for c := 0; c < g.Cols; c++ {
+			s.WriteString(" " + cursor(c == t.cursor, mark) + " ")
+		}
+		line("     %s", s.String())
+	}
+	vertical := !g.Gravity.Horizontal()
+	if vertical && g.Gravity == engine.GravityDown {
+		columns("▼")
+	}
+	var numbers strings.Builder
+	for c := 0; c < g.Cols; c++ {
+		fmt.Fprintf(&numbers, "%2d ", c+1)
+	}
+	line("     %s", numbers.String())
+	for r := 0; r < g.Rows; r++ {
+		var s strings.Builder
+		for c := 0; c < g.Cols; c++ {
+			v := g.Board[r][c]
+			switch {
+			case v == engine.Obstacle:
+				s.WriteString(" \x1b[90m■\x1b[0m ")
+			case v == 0:
+				s.WriteString(" \x1b[2m·\x1b[0m ")
+			case winning[[2]int{r, c}]:
+				s.WriteString("\x1b[7m" + t.token(v, " ● "))
+			case r == g.LastRow && c == g.LastCol:
+				s.WriteString(" " + t.token(v, "◉") + " ")
+			default:
+				s.WriteString(" " + t.token(v, "●") + " ")
+			}
+		}
+		left := cursor(!vertical && g.Gravity == engine.GravityRight && r == t.cursor, "▶")
+		right := cursor(!vertical && g.Gravity == engine.GravityLeft && r == t.cursor, "◀")
+		line("%s %2d │%s│ %s", left, r+1, s.String(), right)
+	}
+	if vertical && g.Gravity == engine.GravityUp {
+		columns("▲")
+	}
+	line("")
+
+	switch {
+	case g.GameOver && g.Winner != 0:
+		line("%s %s gagne en %d coups !", t.token(g.Winner, "●"), g.playerName(g.Winner), len(g.Moves))
+	case g.GameOver:
+		line("Match nul.")
+	case t.remote != nil && g.CurrentPlayer != t.remote.seat && !t.aiTurn():
+		line("%s En attente de %s…", t.token(g.CurrentPlayer, "●"), g.playerName(g.CurrentPlayer))
+	case t.aiTurn():
+		line("%s %s (%s) réfléchit…", t.token(2, "●"), g.playerName(2), g.AILevel.Label())
+	default:
+		entry := "colonne"
+		if !vertical {
+			entry = "ligne"
+		}
+		line("%s Au tour de %s : %s %d", t.token(g.CurrentPlayer, "●"), g.playerName(g.CurrentPlayer), entry, t.cursor+1)
+	}
+	line("%s", t.message)
+	keys := "u annuler · n nouvelle partie"
+	if t.remote != nil {
+		keys = "n rejoindre la revanche"
+	}
+	line("\x1b[2m←→↑↓ choisir · 1-9, 0 : file 10 · Entrée jouer · %s · q quitter\x1b[0m", keys)
+	b.WriteString("\x1b[J")
+	io.WriteString(t.out, b.String())
+}
+
+// play joue à l'indice sélectionné.
+func (t *tui) play() {
+	if t.game.GameOver {
+		t.message = "Partie terminée : n pour rejouer, u pour annuler"
+		return
+	}
+	if t.remote != nil {
+		t.playRemote()
+		return
+	}
+	if !t.game.DropToken(t.cursor) {
+		t.message = "Coup impossible : la file est pleine ou bloquée"
+		return
+	}
+	t.message = ""
+	t.clampCursor()
+}
+
+// run fait tourner la boucle du client jusqu'à ce que le joueur quitte. Les touches sont lues
+// en parallèle pour que les coups reçus du serveur s'affichent sans attendre le joueur.
+func (t *tui) run(r *bufio.Reader) error {
+	keys := make(chan keyPress)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			read := readLine
+			if t.raw {
+				read = readKey
+			}
+			k, err := read(r)
+			if err != nil {
+				errs <- err
+				return
+			}
+			keys <- k
+		}
+	}()
+	for {
+		for t.remote == nil && t.aiTurn() {
+			t.draw()
+			time.Sleep(400 * time.Millisecond)
+			if index := t.game.aiMove(); index < 0 || !t.game.DropToken(index) {
+				break
+			}
+			t.clampCursor()
+		}
+		t.draw()
+		select {
+		case g := <-t.updates:
+			t.update(g)
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case k := <-keys:
+			if quit, err := t.handle(k); quit || err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle traite une touche et indique si le joueur quitte.
+func (t *tui) handle(k keyPress) (bool, error) {
+	switch k.key {
+	case keyPrev:
+		t.cursor--
+		t.clampCursor()
+	case keyNext:
+		t.cursor++
+		t.clampCursor()
+	case keyDigit, keyPlay:
+		if k.index < 0 || k.index >= t.game.MoveCount() {
+			t.message = "Cette file n'existe pas"
+			return false, nil
+		}
+		t.cursor = k.index
+		if k.key == keyPlay {
+			t.play()
+		}
+	case keyDrop:
+		t.play()
+	case keyUndo:
+		t.undo()
+	case keyNew:
+		if t.remote != nil {
+			t.follow()
+			return false, nil
+		}
+		g, err := t.create()
+		if err != nil {
+			return true, err
+		}
+		t.game, t.message = g, "Nouvelle partie"
+		t.clampCursor()
+	case keyQuit:
+		return true, nil
+	}
+	return false, nil
+}
+
+// tuiCommand lance une partie locale dans le terminal.
+func tuiCommand(args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+	difficulty := fs.String("difficulty", "easy", "taille du plateau (easy, normal, hard)")
+	mode := fs.String("mode", "normal", "gravité (normal, inverse, lateral, rotating)")
+	layout := fs.String("layout", "", "plateau de départ personnalisé")
+	ai := fs.String("ai", "", "niveau de l'IA adverse (easy, medium, hard) ; vide pour jouer à deux")
+	name1 := fs.String("p1", "", "nom du joueur 1")
+	name2 := fs.String("p2", "", "nom du joueur 2")
+	server := fs.String("server", "", "adresse d'un serveur Power-4 (ex. http://localhost:8080) pour jouer en ligne")
+	gameID := fs.String("game", "", "avec -server : partie à rejoindre (sinon une nouvelle partie est créée)")
+	seat := fs.Int("seat", 0, "avec -server : siège joué (par défaut 1 pour une partie créée, 2 pour une partie rejointe)")
+	user := fs.String("user", "", "avec -server : compte sous lequel jouer (mot de passe dans POWER4_PASSWORD)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if engine.GravitySchedule(*mode) == nil {
+		return errors.New("mode inconnu : " + *mode)
+	}
+	gameMode, level := ModeHumanVsHuman, engine.AIEasy
+	switch *ai {
+	case "":
+	case "easy":
+		gameMode = ModeHumanVsAI
+	case "medium":
+		gameMode, level = ModeHumanVsAI, engine.AIMedium
+	case "hard":
+		gameMode, level = ModeHumanVsAI, engine.AIHard
+	default:
+		return errors.New("niveau d'IA inconnu : " + *ai)
+	}
+	var t *tui
+	if *server != "" {
+		params := url.Values{"difficulty": {*difficulty}, "mode": {*mode}, "layout": {*layout}, "username": {*name1}, "username2": {*name2}}
+		if gameMode == ModeHumanVsAI {
+			params.Set("gamemode", "ai")
+			params.Set("ailevel", level.String())
+		}
+		password := os.Getenv("POWER4_PASSWORD")
+		if *user != "" && password == "" {
+			return errors.New("-user : mot de passe attendu dans la variable POWER4_PASSWORD")
+		}
+		var err error
+		if t, err = connectTUI(*server, *gameID, *seat, *user, password, params); err != nil {
+			return err
+		}
+		return t.start()
+	}
+
+	var l *engine.Layout
+	if *layout != "" {
+		var err error
+		if l, err = loadLayout(*layout); err != nil {
+			return err
+		}
+	}
+	create := func() (*Game, error) {
+		preset := presetFor(*difficulty)
+		g := NewGame(preset.Rows, preset.Cols, preset.Prefill, preset.Obstacles, *difficulty, *name1, *name2, *mode, "", gameMode, level)
+		if l != nil {
+			if err := g.applyLayout(l); err != nil {
+				return nil, err
+			}
+		}
+		return g, nil
+	}
+	g, err := create()
+	if err != nil {
+		return err
+	}
+	t = &tui{game: g, cursor: g.MoveCount() / 2, create: create, out: os.Stdout}
+	return t.start()
+}
+
+// start prépare le terminal et lance la boucle du client.
+func (t *tui) start() error {
+	if restore, err := rawTerminal(); err == nil {
+		defer restore()
+		t.raw = true
+	} else if t.message == "" {
+		t.message = "Terminal sans mode brut : taper un numéro de file ou une commande puis Entrée"
+	}
+	io.WriteString(t.out, "\x1b[2J\x1b[?25l")
+	defer io.WriteString(t.out, "\x1b[?25h\r\n")
+	return t.run(bufio.NewReader(os.Stdin))
+}